@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// readyz でリポジトリへ問い合わせる時間の上限
+const readinessCheckTimeout = 2 * time.Second
+
+// サーバーが新しいリクエストを受け付けられる状態か
+// Graceful Shutdown を始めたら ShuttingDown を呼び、ロードバランサーに振り分けを止めてもらう
+type Readiness struct {
+	shuttingDown atomic.Bool
+}
+
+// シャットダウンを開始したことを記録する
+// 以降 /readyz は 503 を返す
+func (r *Readiness) ShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// 状態確認の対象
+type healthCheck struct {
+	name string
+	repo interface{}
+}
+
+// GET /healthz のハンドラ
+// プロセスが応答できることだけを返す
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// GET /readyz のハンドラを作成する
+// シャットダウン中、またはいずれかのリポジトリに接続できない場合は 503 を返す
+func readyzHandler(readiness *Readiness, checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if readiness.shuttingDown.Load() {
+			writeHealth(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "shutting down"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		status := http.StatusOK
+		results := map[string]string{}
+		for _, c := range checks {
+			results[c.name] = "ok"
+			// Ping を実装していないリポジトリは常に利用可能とみなす
+			p, ok := c.repo.(repository.Pinger)
+			if !ok {
+				continue
+			}
+			if err := p.Ping(ctx); err != nil {
+				logging.FromContext(r.Context()).Error("readiness check failed", "check", c.name, "error", err)
+				results[c.name] = "unavailable"
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		body := map[string]interface{}{"status": "ok", "checks": results}
+		if status != http.StatusOK {
+			body["status"] = "unavailable"
+		}
+		writeHealth(w, status, body)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	// 状態確認の結果はキャッシュさせない
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
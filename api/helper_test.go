@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// ルーターにリクエストを送信してレスポンスを返す
+// body が空文字列の場合はボディなしで送信する (それ以外は JSON として送信する)
+func serve(t *testing.T, r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var requestBody io.Reader
+	if body != "" {
+		requestBody = bytes.NewBufferString(body)
+	}
+	req, err := http.NewRequest(method, path, requestBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+// 管理用の機能のテストで使うトークン
+const testAdminToken = "test-admin-token"
+
+// すべてのリクエストに管理用のトークンを付けて送るハンドラ
+// api.WithAdminToken(testAdminToken) を指定したルーターを包んで使う
+func asAdmin(r http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		r.ServeHTTP(w, req)
+	})
+}
+
+// インメモリDBと同じ初期データを登録したSQLバックエンドのルーターを作成する
+func newSeededSQLRouter(t *testing.T, opts ...api.Option) http.Handler {
+	t.Helper()
+	db, _ := openTestDB(t)
+	r := api.NewRouter(append([]api.Option{api.WithSQLDB(db)}, opts...)...)
+	for _, body := range []string{
+		`{"name": "Alice"}`, `{"name": "Bella"}`, `{"name": "Chris"}`, `{"name": "Daisy"}`, `{"name": "Ellen"}`,
+	} {
+		if rr := serve(t, r, "POST", "/singers", body); rr.Code != http.StatusCreated {
+			t.Fatalf("seed singer: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	for _, body := range []string{
+		`{"title": "Alice's 1st Album", "singer_id": 1}`,
+		`{"title": "Alice's 2nd Album", "singer_id": 1}`,
+		`{"title": "Bella's 1st Album", "singer_id": 2}`,
+	} {
+		if rr := serve(t, r, "POST", "/albums", body); rr.Code != http.StatusCreated {
+			t.Fatalf("seed album: %d %s", rr.Code, rr.Body.String())
+		}
+	}
+	return r
+}
+
+// テスト対象のバックエンド
+// opts はどちらのバックエンドのルーターにも指定する
+func backends(opts ...api.Option) map[string]func(t *testing.T) http.Handler {
+	return map[string]func(t *testing.T) http.Handler{
+		"memorydb": func(t *testing.T) http.Handler { return api.NewRouter(opts...) },
+		"sqldb":    func(t *testing.T) http.Handler { return newSeededSQLRouter(t, opts...) },
+	}
+}
+
+// レスポンスの JSON から作成・更新日時 (created_at, updated_at) を取り除く
+// 日時を確認しないテストで、レスポンス全体を比較するために使う
+func withoutTimestamps(t *testing.T, body []byte) string {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("invalid JSON: %v: %s", err, body)
+	}
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			delete(v, "created_at")
+			delete(v, "updated_at")
+			for _, e := range v {
+				strip(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				strip(e)
+			}
+		}
+	}
+	strip(v)
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// 歌手が1人だけ (メインの歌手) のアルバムの参加歌手
+func soloArtists(singerID model.SingerID) []model.AlbumArtist {
+	return []model.AlbumArtist{{SingerID: singerID, Role: model.ArtistRolePrimary}}
+}
+
+// 歌手が1人だけ (メインの歌手) のアルバムのレスポンスの参加歌手
+func soloCredit(singerID model.SingerID, name string) []*model.CreditedSinger {
+	return []*model.CreditedSinger{{Singer: &model.Singer{ID: singerID, Name: name}, Role: model.ArtistRolePrimary}}
+}
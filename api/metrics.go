@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// メトリクスの取得時にリポジトリへ問い合わせる時間の上限
+const repositoryCountTimeout = 5 * time.Second
+
+// リポジトリに登録されている歌手・アルバムの数を公開するコレクタ
+// 値はメトリクスの取得時にリポジトリから読み込む
+type repositoryCollector struct {
+	singerRepo repository.SingerRepository
+	albumRepo  repository.AlbumRepository
+	singers    *prometheus.Desc
+	albums     *prometheus.Desc
+}
+
+func newRepositoryCollector(singerRepo repository.SingerRepository, albumRepo repository.AlbumRepository) *repositoryCollector {
+	return &repositoryCollector{
+		singerRepo: singerRepo,
+		albumRepo:  albumRepo,
+		singers:    prometheus.NewDesc("app_singers", "Number of singers held by the repository.", nil, nil),
+		albums:     prometheus.NewDesc("app_albums", "Number of albums held by the repository.", nil, nil),
+	}
+}
+
+func (c *repositoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.singers
+	ch <- c.albums
+}
+
+func (c *repositoryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), repositoryCountTimeout)
+	defer cancel()
+
+	// 取得に失敗した場合はエラーとして報告し、他のメトリクスは返す
+	if n, err := c.singerRepo.Count(ctx); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.singers, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.singers, prometheus.GaugeValue, float64(n))
+	}
+	if n, err := c.albumRepo.Count(ctx); err != nil {
+		ch <- prometheus.NewInvalidMetric(c.albums, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.albums, prometheus.GaugeValue, float64(n))
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/controller"
+)
+
+// 管理用のリクエストをトークンで保護するミドルウェアを返す
+// isAdmin が true を返すリクエストは Authorization: Bearer <token> が一致する場合だけ通し、
+// 一致しない場合は 401 Unauthorized を返す
+// token が空の場合は管理用の機能を無効にし、403 Forbidden を返す
+func AdminToken(token string, isAdmin func(*http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !isAdmin(req) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if token == "" {
+				controller.WriteError(w, req, http.StatusForbidden, "admin API is disabled")
+				return
+			}
+			// トークンの比較にかかる時間から内容を推測されないようにする
+			given, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				controller.WriteError(w, req, http.StatusUnauthorized, "a valid admin token is required")
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
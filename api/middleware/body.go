@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// リクエストボディの大きさを n バイトまでに制限するミドルウェアを返す
+// 超えた分を読み込もうとすると *http.MaxBytesError になる
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Body != nil {
+				req.Body = http.MaxBytesReader(w, req.Body, n)
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
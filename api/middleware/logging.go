@@ -1,38 +1,138 @@
 package middleware
 
 import (
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
 )
 
+// リクエストIDを受け渡しするヘッダ
+const RequestIDHeader = "X-Request-ID"
+
+// クライアントから受け取るリクエストIDの最大長
+const maxRequestIDLength = 128
+
 type loggingWriter struct {
 	http.ResponseWriter
-	code int
+	code        int
+	bytes       int
+	wroteHeader bool // ステータスコードを送信済みか
+	aborted     bool // panic でレスポンスを最後まで返さずに接続を切ったか
 }
 
 // コンストラクタ
 func newLoggingWriter(w http.ResponseWriter) *loggingWriter {
-	// 初期値として500を設定
-	return &loggingWriter{ResponseWriter: w, code: http.StatusInternalServerError}
+	// WriteHeader を呼ばずに書き込んだ場合は200になるので、初期値として200を設定
+	return &loggingWriter{ResponseWriter: w, code: http.StatusOK}
 }
 
 // ステータスコードを書き込む関数
 func (lw *loggingWriter) WriteHeader(code int) {
 	lw.code = code
+	lw.wroteHeader = true
 	lw.ResponseWriter.WriteHeader(code)
 }
 
+// レスポンスボディを書き込み、書き込んだバイト数を記録する
+func (lw *loggingWriter) Write(b []byte) (int, error) {
+	lw.wroteHeader = true
+	n, err := lw.ResponseWriter.Write(b)
+	lw.bytes += n
+	return n, err
+}
+
+// 元の ResponseWriter を返す
+// http.ResponseController で書き込みの期限などを設定できるようにする
+func (lw *loggingWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// next でリクエストを処理し、record で記録する
+// panic で接続が切られる場合も、panic を net/http に渡す前に中断したものとして記録する
+// (RecoveryMiddleware を通り抜けるのは http.ErrAbortHandler だけ)
+func serveAndRecord(next http.Handler, rlw *loggingWriter, req *http.Request, record func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			rlw.aborted = true
+			record()
+			panic(v)
+		}
+	}()
+	next.ServeHTTP(rlw, req)
+	record()
+}
+
 // http.HandlerFuncを返す
+// 1リクエストにつき1行、処理が終わったときにログを出力する
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		log.Printf("uri: %s, method: %s\n", req.RequestURI, req.Method)
+		start := time.Now()
+
+		// リクエストIDを引き継ぐか新しく払い出し、レスポンスとコンテキストに設定する
+		requestID := req.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logging.WithRequestID(req.Context(), requestID)
 
 		// ロギング用のレスポンスラッパーを作成
 		rlw := newLoggingWriter(w)
 
 		// HTTPリクエストを処理
-		next.ServeHTTP(rlw, req)
-
-		log.Printf("response code: %d", rlw.code)
+		serveAndRecord(next, rlw, req.WithContext(ctx), func() {
+			level := slog.LevelInfo
+			if rlw.code >= http.StatusInternalServerError || rlw.aborted {
+				level = slog.LevelError
+			}
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("route", routeTemplate(req)),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", rlw.code),
+				slog.Int("bytes", rlw.bytes),
+				slog.Duration("latency", time.Since(start)),
+			}
+			if rlw.aborted {
+				attrs = append(attrs, slog.Bool("aborted", true))
+			}
+			logging.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
+		})
 	})
 }
+
+// マッチしたルートのテンプレート (例: /singers/{id:[1-9][0-9]*})
+// パスパラメータごとに分かれないよう、ログやメトリクスにはパスの代わりにこちらを使う
+func routeTemplate(req *http.Request) string {
+	if cr := mux.CurrentRoute(req); cr != nil {
+		if tpl, err := cr.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return ""
+}
+
+// ログやヘッダに埋め込んでも問題ない文字だけで構成されているか
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// ランダムなリクエストIDを作成する
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
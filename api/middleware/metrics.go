@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPリクエストのメトリクス
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// コンストラクタ
+// メトリクスは reg に登録する
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	labels := []string{"method", "route", "status"}
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by method, route template and status code.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by method, route template and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, labels),
+	}
+	reg.MustRegister(m.requests, m.duration)
+	return m
+}
+
+// リクエスト数と処理時間を記録するミドルウェア
+// LoggingMiddleware の内側で使う場合は、ステータスコードの記録を共有する
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		rlw, ok := w.(*loggingWriter)
+		if !ok {
+			rlw = newLoggingWriter(w)
+		}
+		serveAndRecord(next, rlw, req, func() {
+			// パスパラメータごとに系列が増えないよう、ルートのテンプレートをラベルにする
+			// 途中で接続を切ったレスポンスは、送信済みのステータスコードではなく aborted として数える
+			status := strconv.Itoa(rlw.code)
+			if rlw.aborted {
+				status = "aborted"
+			}
+			labels := prometheus.Labels{
+				"method": req.Method,
+				"route":  routeTemplate(req),
+				"status": status,
+			}
+			m.requests.With(labels).Inc()
+			m.duration.With(labels).Observe(time.Since(start).Seconds())
+		})
+	})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/pulse227/server-recruit-challenge-sample/controller"
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
+)
+
+// ハンドラ内の panic を回復し、500 Internal Server Error を返すミドルウェア
+// リクエストIDを記録できるよう、LoggingMiddleware の内側で使う
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rlw, ok := w.(*loggingWriter)
+		if !ok {
+			rlw = newLoggingWriter(w)
+		}
+
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// 接続の中断を意図した panic はそのまま net/http に任せる
+			// 外側の LoggingMiddleware や Metrics は中断したリクエストとして記録する
+			if v == http.ErrAbortHandler {
+				rlw.aborted = true
+				panic(v)
+			}
+			logging.FromContext(req.Context()).Error("panic recovered",
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+			// すでにレスポンスを書き始めている場合はステータスコードを変更できない
+			if rlw.wroteHeader {
+				return
+			}
+			controller.WriteError(rlw, req, http.StatusInternalServerError, "internal server error")
+		}()
+
+		next.ServeHTTP(rlw, req)
+	})
+}
@@ -0,0 +1,96 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/pulse227/server-recruit-challenge-sample/api/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// ハンドラ内の panic を回復して problem+json のエラーを返す
+func TestRecoveryMiddleware(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	var logs bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	t.Run("Panic", func(t *testing.T) {
+		logs.Reset()
+		h := middleware.LoggingMiddleware(middleware.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p *struct{ Name string }
+			_ = p.Name // nil ポインタの参照
+		})))
+
+		req := httptest.NewRequest("POST", "/albums", nil)
+		req.Header.Set("X-Request-ID", "panic-request")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Internal Server Error",
+			"status": 500,
+			"detail": "internal server error",
+			"instance": "/albums",
+			"request_id": "panic-request"
+		}`, rr.Body.String())
+
+		// スタックトレースをリクエストIDと一緒に記録する
+		assert.Contains(t, logs.String(), `"msg":"panic recovered"`)
+		assert.Contains(t, logs.String(), `"request_id":"panic-request"`)
+		assert.Contains(t, logs.String(), "recovery_test.go")
+		// アクセスログにも 500 として残る
+		assert.Contains(t, logs.String(), `"status":500`)
+	})
+
+	// レスポンスを書き始めた後の panic ではレスポンスを変更しない
+	t.Run("AfterWrite", func(t *testing.T) {
+		logs.Reset()
+		h := middleware.RecoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("partial"))
+			panic("boom")
+		}))
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/singers", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "partial", rr.Body.String())
+		assert.Contains(t, logs.String(), `"panic":"boom"`)
+	})
+
+	// http.ErrAbortHandler は net/http に任せ、接続を切る前にログとメトリクスに記録する
+	t.Run("Abort", func(t *testing.T) {
+		logs.Reset()
+		reg := prometheus.NewRegistry()
+		h := middleware.LoggingMiddleware(middleware.NewMetrics(reg).Middleware(middleware.RecoveryMiddleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("partial"))
+				panic(http.ErrAbortHandler)
+			}))))
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/export", nil))
+		})
+
+		assert.NotContains(t, logs.String(), `"msg":"panic recovered"`)
+		assert.Contains(t, logs.String(), `"level":"ERROR","msg":"request"`)
+		assert.Contains(t, logs.String(), `"status":200,"bytes":7`)
+		assert.Contains(t, logs.String(), `"aborted":true`)
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP http_requests_total Number of HTTP requests by method, route template and status code.
+			# TYPE http_requests_total counter
+			http_requests_total{method="GET",route="",status="aborted"} 1
+		`), "http_requests_total"))
+	})
+}
@@ -1,38 +1,194 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/pulse227/server-recruit-challenge-sample/api/middleware"
 	"github.com/pulse227/server-recruit-challenge-sample/controller"
 	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"github.com/pulse227/server-recruit-challenge-sample/seed"
 	"github.com/pulse227/server-recruit-challenge-sample/service"
 )
 
-func NewRouter() *mux.Router {
+// ルーターの設定
+type routerOptions struct {
+	singerRepo         repository.SingerRepository
+	albumRepo          repository.AlbumRepository
+	trackRepo          repository.TrackRepository
+	db                 *sql.DB
+	clock              func() time.Time
+	singerDeletePolicy service.SingerDeletePolicy
+	readiness          *Readiness
+	maxBodyBytes       int64
+	adminToken         string
+	adminMaxBodyBytes  int64
+	adminTimeout       time.Duration
+}
+
+// リクエストボディの大きさの上限の既定値 (1MiB)
+const DefaultMaxBodyBytes = 1 << 20
+
+// 管理用の API (一括取り込み) のリクエストボディの大きさの上限の既定値 (64MiB)
+const DefaultAdminMaxBodyBytes = 64 << 20
+
+// ルーターのオプション
+type Option func(*routerOptions)
+
+// 永続化にSQLデータベースを使用する
+// db は sqldb.Open で開いたものを渡すこと
+func WithSQLDB(db *sql.DB) Option {
+	return func(o *routerOptions) {
+		o.db = db
+		o.singerRepo, o.albumRepo, o.trackRepo = nil, nil, nil
+	}
+}
+
+// 任意のリポジトリ実装を使用する
+func WithRepositories(singerRepo repository.SingerRepository, albumRepo repository.AlbumRepository) Option {
+	return func(o *routerOptions) {
+		o.singerRepo = singerRepo
+		o.albumRepo = albumRepo
+		o.db = nil
+	}
+}
+
+// 任意のトラックのリポジトリ実装を使用する
+// 指定しない場合は WithSQLDB ではSQLデータベース、それ以外は空のインメモリDBを使用する
+func WithTrackRepository(trackRepo repository.TrackRepository) Option {
+	return func(o *routerOptions) {
+		o.trackRepo = trackRepo
+	}
+}
+
+// リポジトリが作成・更新日時に使う現在時刻の取得方法を指定する
+// WithRepositories で渡したリポジトリには適用しない
+func WithClock(now func() time.Time) Option {
+	return func(o *routerOptions) {
+		o.clock = now
+	}
+}
+
+// アルバムから参照されている歌手を削除するときの方針を指定する
+func WithSingerDeletePolicy(policy service.SingerDeletePolicy) Option {
+	return func(o *routerOptions) {
+		o.singerDeletePolicy = policy
+	}
+}
+
+// シャットダウンの開始を /readyz に反映するための状態を指定する
+func WithReadiness(readiness *Readiness) Option {
+	return func(o *routerOptions) {
+		o.readiness = readiness
+	}
+}
+
+// リクエストボディの大きさの上限を指定する
+// 上限を超えたリクエストには 413 Request Entity Too Large を返す
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *routerOptions) {
+		o.maxBodyBytes = n
+	}
+}
+
+// 管理用の API (/admin/) と include_deleted に必要なトークンを指定する
+// リクエストの Authorization: Bearer <token> で渡す。指定しない場合は管理用の機能を無効にする
+func WithAdminToken(token string) Option {
+	return func(o *routerOptions) {
+		o.adminToken = token
+	}
+}
+
+// 管理用の API のリクエストボディの大きさの上限を指定する
+// 一括取り込みのために WithMaxBodyBytes の上限とは別に指定する
+func WithAdminMaxBodyBytes(n int64) Option {
+	return func(o *routerOptions) {
+		o.adminMaxBodyBytes = n
+	}
+}
+
+// 管理用の API の読み書きのタイムアウトを指定する (0 の場合は無制限)
+// サーバーの ReadTimeout, WriteTimeout の代わりに使い、大きなデータの取り込み・書き出しを途中で切らないようにする
+func WithAdminTimeout(d time.Duration) Option {
+	return func(o *routerOptions) {
+		o.adminTimeout = d
+	}
+}
+
+// オプションを指定しない場合はサンプルデータを投入したインメモリDBを使用し、
+// 参照されている歌手の削除は拒否する。管理用の機能は WithAdminToken を指定した場合だけ使える
+func NewRouter(opts ...Option) *mux.Router {
+	o := &routerOptions{
+		singerDeletePolicy: service.SingerDeleteRestrict,
+		readiness:          &Readiness{},
+		maxBodyBytes:       DefaultMaxBodyBytes,
+		adminMaxBodyBytes:  DefaultAdminMaxBodyBytes,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	var memOpts []memorydb.Option
+	if o.clock != nil {
+		memOpts = append(memOpts, memorydb.WithClock(o.clock))
+	}
+	switch {
+	case o.db != nil:
+		var repoOpts []sqldb.Option
+		if o.clock != nil {
+			repoOpts = append(repoOpts, sqldb.WithClock(o.clock))
+		}
+		o.singerRepo = sqldb.NewSingerRepository(o.db, repoOpts...)
+		o.albumRepo = sqldb.NewAlbumRepository(o.db, repoOpts...)
+		if o.trackRepo == nil {
+			o.trackRepo = sqldb.NewTrackRepository(o.db, repoOpts...)
+		}
+	case o.singerRepo == nil || o.albumRepo == nil:
+		o.singerRepo = memorydb.NewSingerRepository(memOpts...)
+		o.albumRepo = memorydb.NewAlbumRepository(memOpts...)
+		// 空のインメモリDBへの投入は失敗しない
+		if err := seed.Apply(context.Background(), o.singerRepo, o.albumRepo, seed.Sample()); err != nil {
+			panic(err)
+		}
+	}
+	if o.trackRepo == nil {
+		o.trackRepo = memorydb.NewTrackRepository(memOpts...)
+	}
+
 	// 歌手情報
-	// 歌手DBの作成
-	singerRepo := memorydb.NewSingerRepository()
 	// 歌手サービスの作成
-	singerService := service.NewSingerService(singerRepo)
+	singerService := service.NewSingerService(o.singerRepo, o.albumRepo, o.singerDeletePolicy)
 	// 歌手コントローラの作成
 	singerController := controller.NewSingerController(singerService)
 
 	// アルバム情報
-	// アルバムDBの作成
-	albumRepo := memorydb.NewAlbumRepository()
 	// アルバムサービスの作成
-	albumService := service.NewAlbumService(albumRepo)
+	albumService := service.NewAlbumService(o.albumRepo, o.singerRepo)
 	// アルバムコントローラの作成 (課題3の場合はこっち)
 	// albumController := controller.NewAlbumController(albumService)
 
+	// トラック情報
+	trackService := service.NewTrackService(o.trackRepo, o.albumRepo)
+	trackController := controller.NewTrackController(trackService)
+
 	// アルバム + 歌手情報
-	albumSingerService := service.NewAlbumSingerService(albumService, singerService)
+	albumSingerService := service.NewAlbumSingerService(albumService, singerService, trackService)
 	// アルバムコントローラの作成
 	// (課題4の場合はこっち)
 	albumController := controller.NewAlbumSingerController(albumSingerService)
 
+	// カタログの一括取り込み・書き出し
+	catalogService := service.NewCatalogService(o.singerRepo, o.albumRepo)
+	catalogController := controller.NewCatalogController(catalogService, o.adminTimeout)
+
 	// ルータの作成
 	r := mux.NewRouter()
 
@@ -41,15 +197,83 @@ func NewRouter() *mux.Router {
 	r.HandleFunc("/singers", singerController.GetSingerListHandler).Methods(http.MethodGet) // GET /singers
 	r.HandleFunc("/singers/{id:[1-9][0-9]*}", singerController.GetSingerDetailHandler).Methods(http.MethodGet)
 	r.HandleFunc("/singers", singerController.PostSingerHandler).Methods(http.MethodPost)
+	r.HandleFunc("/singers/{id:[1-9][0-9]*}", singerController.PutSingerHandler).Methods(http.MethodPut)
+	r.HandleFunc("/singers/{id:[1-9][0-9]*}", singerController.PatchSingerHandler).Methods(http.MethodPatch)
 	r.HandleFunc("/singers/{id:[1-9][0-9]*}", singerController.DeleteSingerHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/singers/{id:[1-9][0-9]*}:restore", singerController.RestoreSingerHandler).Methods(http.MethodPost)
+	// 歌手ごとのアルバム
+	r.HandleFunc("/singers/{id:[1-9][0-9]*}/albums", albumController.GetSingerAlbumListHandler).Methods(http.MethodGet)
+	r.HandleFunc("/singers/{id:[1-9][0-9]*}/albums", albumController.PostSingerAlbumHandler).Methods(http.MethodPost)
 	// アルバム
 	r.HandleFunc("/albums", albumController.GetAlbumListHandler).Methods(http.MethodGet)
 	r.HandleFunc("/albums/{id:[1-9][0-9]*}", albumController.GetAlbumDetailHandler).Methods(http.MethodGet)
 	r.HandleFunc("/albums", albumController.PostAlbumHandler).Methods(http.MethodPost)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}", albumController.PutAlbumHandler).Methods(http.MethodPut)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}", albumController.PatchAlbumHandler).Methods(http.MethodPatch)
 	r.HandleFunc("/albums/{id:[1-9][0-9]*}", albumController.DeleteAlbumHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}:restore", albumController.RestoreAlbumHandler).Methods(http.MethodPost)
+	// アルバムのトラック
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}/tracks", trackController.GetTrackListHandler).Methods(http.MethodGet)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}/tracks", trackController.PostTrackHandler).Methods(http.MethodPost)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}/tracks/{number:[1-9][0-9]*}", trackController.GetTrackDetailHandler).Methods(http.MethodGet)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}/tracks/{number:[1-9][0-9]*}", trackController.PutTrackHandler).Methods(http.MethodPut)
+	r.HandleFunc("/albums/{id:[1-9][0-9]*}/tracks/{number:[1-9][0-9]*}", trackController.DeleteTrackHandler).Methods(http.MethodDelete)
 
-	// ミドルウェアの設定 (ログ出力)
+	// 管理用
+	r.HandleFunc("/admin/import", catalogController.ImportHandler).Methods(http.MethodPost)
+	r.HandleFunc("/admin/export", catalogController.ExportHandler).Methods(http.MethodGet)
+
+	// 死活監視
+	r.HandleFunc("/healthz", healthzHandler).Methods(http.MethodGet)
+	r.Handle("/readyz", readyzHandler(o.readiness, []healthCheck{
+		{name: "singers", repo: o.singerRepo},
+		{name: "albums", repo: o.albumRepo},
+		{name: "tracks", repo: o.trackRepo},
+	})).Methods(http.MethodGet)
+
+	// メトリクス
+	// ルーターごとにレジストリを作成し、複数のルーターを作成しても登録が衝突しないようにする
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newRepositoryCollector(o.singerRepo, o.albumRepo),
+	)
+	r.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{})).Methods(http.MethodGet)
+
+	// ミドルウェアの設定 (ログ出力、メトリクス、panic の回復、管理用の機能の認証、ボディの大きさの制限)
+	// ログ出力の内側に置き、ステータスコードの記録とリクエストIDを共有する
 	r.Use(middleware.LoggingMiddleware)
+	r.Use(middleware.NewMetrics(reg).Middleware)
+	r.Use(middleware.RecoveryMiddleware)
+	r.Use(middleware.AdminToken(o.adminToken, isAdminRequest))
+	r.Use(maxBodyBytes(o.maxBodyBytes, o.adminMaxBodyBytes))
 
 	return r
 }
+
+// 管理用の API か、論理削除したデータを含めるリクエストか
+func isAdminRequest(req *http.Request) bool {
+	return isAdminPath(req) || req.URL.Query().Has("include_deleted")
+}
+
+// 管理用の API (/admin/) のリクエストか
+func isAdminPath(req *http.Request) bool {
+	return strings.HasPrefix(req.URL.Path, "/admin/")
+}
+
+// リクエストボディの大きさを制限するミドルウェアを返す
+// 管理用の API は一括取り込みのために adminN バイト、それ以外は n バイトまでにする
+func maxBodyBytes(n, adminN int64) func(http.Handler) http.Handler {
+	limit, adminLimit := middleware.MaxBodyBytes(n), middleware.MaxBodyBytes(adminN)
+	return func(next http.Handler) http.Handler {
+		h, admin := limit(next), adminLimit(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if isAdminPath(req) {
+				admin.ServeHTTP(w, req)
+				return
+			}
+			h.ServeHTTP(w, req)
+		})
+	}
+}
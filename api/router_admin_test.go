@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// 管理用の機能 (/admin/ と include_deleted) はトークンを指定したときだけ使える
+func TestAdminToken(t *testing.T) {
+	paths := []string{"/admin/export", "/singers?include_deleted=true", "/albums?include_deleted=false"}
+
+	t.Run("Disabled", func(t *testing.T) {
+		r := asAdmin(api.NewRouter())
+		for _, path := range paths {
+			rr := serve(t, r, "GET", path, "")
+			assert.Equal(t, http.StatusForbidden, rr.Code, path)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		r := api.NewRouter(api.WithAdminToken(testAdminToken))
+		for _, authorization := range []string{"", "Bearer wrong-token", testAdminToken} {
+			for _, path := range paths {
+				req := httptest.NewRequest("GET", path, nil)
+				if authorization != "" {
+					req.Header.Set("Authorization", authorization)
+				}
+				rr := httptest.NewRecorder()
+				r.ServeHTTP(rr, req)
+				assert.Equal(t, http.StatusUnauthorized, rr.Code, path)
+				assert.Equal(t, `Bearer realm="admin"`, rr.Header().Get("WWW-Authenticate"))
+			}
+		}
+	})
+
+	t.Run("Authorized", func(t *testing.T) {
+		r := asAdmin(api.NewRouter(api.WithAdminToken(testAdminToken)))
+		for _, path := range paths {
+			rr := serve(t, r, "GET", path, "")
+			assert.Equal(t, http.StatusOK, rr.Code, path)
+		}
+	})
+
+	// 管理用ではないリクエストにはトークンは不要
+	t.Run("Public", func(t *testing.T) {
+		rr := serve(t, api.NewRouter(), "GET", "/singers", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+// 一括取り込みには通常のリクエストとは別のボディの上限を使う
+func TestAdminMaxBodyBytes(t *testing.T) {
+	var lines []string
+	for i := 10; i < 15; i++ {
+		lines = append(lines, fmt.Sprintf(`{"singer": {"id": %d, "name": "Singer %d"}}`, i, i))
+	}
+	body := strings.Join(lines, "\n")
+	r := asAdmin(api.NewRouter(
+		api.WithAdminToken(testAdminToken),
+		api.WithMaxBodyBytes(100),
+		api.WithAdminMaxBodyBytes(1000),
+	))
+	rr := serveWithContentType(t, r, "POST", "/admin/import?dry_run=true", "application/x-ndjson", body)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	rr = serve(t, r, "POST", "/singers", `{"name": "Ivy", "aliases": ["`+strings.Repeat("a", 100)+`"]}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	r = asAdmin(api.NewRouter(api.WithAdminToken(testAdminToken), api.WithAdminMaxBodyBytes(100)))
+	rr = serveWithContentType(t, r, "POST", "/admin/import?dry_run=true", "application/x-ndjson", body)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+// 書き出しはサーバーの WriteTimeout ではなく管理用のタイムアウトで書き込む
+func TestAdminTimeout(t *testing.T) {
+	srv := httptest.NewUnstartedServer(api.NewRouter(api.WithAdminToken(testAdminToken), api.WithAdminTimeout(time.Minute)))
+	// ハンドラが書き込む前に期限が切れる
+	srv.Config.WriteTimeout = time.Nanosecond
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/admin/export?format=ndjson", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 8)
+}
@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// 複数の歌手が参加するアルバム
+func TestAlbumArtists(t *testing.T) {
+	// アルバム一覧のIDを取得する
+	albumIDs := func(t *testing.T, r http.Handler, path string) []int {
+		t.Helper()
+		rr := serve(t, r, "GET", path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s returned %d", path, rr.Code)
+		}
+		var albums []struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &albums); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0, len(albums))
+		for _, a := range albums {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Post", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/albums", `{"title": "Duet", "artists": [
+					{"singer_id": 2, "role": "primary"}, {"singer_id": 3, "role": "featured"}, {"singer_id": 2, "role": "producer"}
+				]}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				assert.JSONEq(t, `{"id": 4, "title": "Duet", "singer_id": 2, "artists": [
+					{"singer_id": 2, "role": "primary"}, {"singer_id": 3, "role": "featured"}, {"singer_id": 2, "role": "producer"}
+				]}`, withoutTimestamps(t, rr.Body.Bytes()))
+
+				// singer は最初のメインの歌手、singers は登録した順
+				rr = serve(t, r, "GET", "/albums/4", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `{"id": 4, "title": "Duet", "singer": {"id": 2, "name": "Bella"}, "singers": [
+					{"id": 2, "name": "Bella", "role": "primary"},
+					{"id": 3, "name": "Chris", "role": "featured"},
+					{"id": 2, "name": "Bella", "role": "producer"}
+				]}`, withoutTimestamps(t, rr.Body.Bytes()))
+
+				// singer_id だけを指定した場合はメインの歌手になる
+				rr = serve(t, r, "POST", "/albums", `{"title": "Solo", "singer_id": 4}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				assert.JSONEq(t, `{"id": 5, "title": "Solo", "singer_id": 4, "artists": [{"singer_id": 4, "role": "primary"}]}`,
+					withoutTimestamps(t, rr.Body.Bytes()))
+
+				// 存在しない歌手は登録できない
+				rr = serve(t, r, "POST", "/albums", `{"title": "T", "artists": [{"singer_id": 1, "role": "primary"}, {"singer_id": 99, "role": "featured"}]}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			})
+
+			t.Run("Validation", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/albums", `{"title": "T", "singer_id": 3, "artists": [
+					{"singer_id": 1, "role": "primary"}, {"singer_id": 2, "role": "singer"}, {"singer_id": 1, "role": "primary"}, {"role": "featured"}
+				]}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				var problem struct {
+					Errors []struct {
+						Field string `json:"field"`
+						Code  string `json:"code"`
+					} `json:"errors"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+					t.Fatal(err)
+				}
+				codes := map[string]string{}
+				for _, e := range problem.Errors {
+					codes[e.Field] = e.Code
+				}
+				assert.Equal(t, map[string]string{
+					"artists[1].role":      "invalid_value",
+					"artists[2]":           "duplicate",
+					"artists[3].singer_id": "required",
+					"singer_id":            "mismatch",
+				}, codes)
+
+				// メインの歌手がいない
+				rr = serve(t, r, "POST", "/albums", `{"title": "T", "artists": [{"singer_id": 1, "role": "featured"}]}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				assert.Contains(t, rr.Body.String(), `"field":"artists"`)
+			})
+
+			// 参加しているアルバムはすべて歌手のアルバムとして扱う
+			t.Run("Filter", func(t *testing.T) {
+				r := newRouter(t)
+				serve(t, r, "POST", "/albums", `{"title": "Feat", "artists": [{"singer_id": 2, "role": "primary"}, {"singer_id": 1, "role": "featured"}]}`)
+
+				assert.Equal(t, []int{1, 2, 4}, albumIDs(t, r, "/albums?singer_id=1"))
+				assert.Equal(t, []int{1, 2, 4}, albumIDs(t, r, "/singers/1/albums"))
+				assert.Equal(t, []int{3, 4}, albumIDs(t, r, "/albums?singer_id=2"))
+			})
+
+			// singer_id だけを変更するとメインの歌手が入れ替わり、他の参加歌手は残る
+			t.Run("PatchSingerID", func(t *testing.T) {
+				r := newRouter(t)
+				serve(t, r, "POST", "/albums", `{"title": "Feat", "artists": [{"singer_id": 2, "role": "primary"}, {"singer_id": 1, "role": "featured"}]}`)
+
+				rr := serve(t, r, "PATCH", "/albums/4", `{"singer_id": 3}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serve(t, r, "GET", "/albums/4", "")
+				assert.JSONEq(t, `{"id": 4, "title": "Feat", "singer": {"id": 3, "name": "Chris"}, "singers": [
+					{"id": 3, "name": "Chris", "role": "primary"},
+					{"id": 1, "name": "Alice", "role": "featured"}
+				]}`, withoutTimestamps(t, rr.Body.Bytes()))
+			})
+		})
+	}
+
+	// 参加しているだけの歌手を削除してもアルバムは残り、参加歌手から外れる
+	for _, policy := range []service.SingerDeletePolicy{service.SingerDeleteCascade, service.SingerDeleteOrphan} {
+		for name, newRouter := range backends(api.WithSingerDeletePolicy(policy)) {
+			t.Run(string(policy)+"/"+name, func(t *testing.T) {
+				r := newRouter(t)
+				serve(t, r, "POST", "/albums", `{"title": "Feat", "artists": [{"singer_id": 2, "role": "primary"}, {"singer_id": 1, "role": "featured"}]}`)
+
+				rr := serve(t, r, "DELETE", "/singers/1", "")
+				assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+				rr = serve(t, r, "GET", "/albums/4", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `{"id": 4, "title": "Feat", "singer": {"id": 2, "name": "Bella"}, "singers": [{"id": 2, "name": "Bella", "role": "primary"}]}`,
+					withoutTimestamps(t, rr.Body.Bytes()))
+			})
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// カタログの一括取り込みと書き出し
+func TestCatalog(t *testing.T) {
+	// 空のバックエンド
+	emptyBackends := map[string]func(t *testing.T) http.Handler{
+		"empty-memorydb": func(t *testing.T) http.Handler {
+			return asAdmin(api.NewRouter(api.WithRepositories(memorydb.NewSingerRepository(), memorydb.NewAlbumRepository()), api.WithAdminToken(testAdminToken)))
+		},
+		"empty-sqldb": func(t *testing.T) http.Handler {
+			db, _ := openTestDB(t)
+			return asAdmin(api.NewRouter(api.WithSQLDB(db), api.WithAdminToken(testAdminToken)))
+		},
+	}
+
+	// 行ごとのエラーを "行番号 項目名" をキーにしたコードのマップにする
+	lineErrors := func(t *testing.T, body []byte) map[string]string {
+		t.Helper()
+		var problem struct {
+			Errors []struct {
+				Line  int    `json:"line"`
+				Field string `json:"field"`
+				Code  string `json:"code"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &problem); err != nil {
+			t.Fatal(err)
+		}
+		errs := map[string]string{}
+		for _, e := range problem.Errors {
+			errs[strings.TrimSpace(strconv.Itoa(e.Line)+" "+e.Field)] = e.Code
+		}
+		return errs
+	}
+
+	for name, newRouter := range backends(api.WithAdminToken(testAdminToken)) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Export", func(t *testing.T) {
+				r := asAdmin(newRouter(t))
+				rr := serve(t, r, "GET", "/admin/export", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+				assert.JSONEq(t, `{
+					"singers": [{"id": 1, "name": "Alice"}, {"id": 2, "name": "Bella"}, {"id": 3, "name": "Chris"}, {"id": 4, "name": "Daisy"}, {"id": 5, "name": "Ellen"}],
+					"albums": [
+						{"id": 1, "title": "Alice's 1st Album", "singer_id": 1, "artists": [{"singer_id": 1, "role": "primary"}]},
+						{"id": 2, "title": "Alice's 2nd Album", "singer_id": 1, "artists": [{"singer_id": 1, "role": "primary"}]},
+						{"id": 3, "title": "Bella's 1st Album", "singer_id": 2, "artists": [{"singer_id": 2, "role": "primary"}]}
+					]
+				}`, withoutTimestamps(t, rr.Body.Bytes()))
+
+				// 論理削除したものは含めない
+				serve(t, r, "DELETE", "/albums/2", "")
+				rr = serve(t, r, "GET", "/admin/export?format=ndjson", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+				lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+				if assert.Len(t, lines, 7) {
+					assert.JSONEq(t, `{"singer": {"id": 1, "name": "Alice"}}`, withoutTimestamps(t, []byte(lines[0])))
+					assert.JSONEq(t, `{"album": {"id": 3, "title": "Bella's 1st Album", "singer_id": 2, "artists": [{"singer_id": 2, "role": "primary"}]}}`,
+						withoutTimestamps(t, []byte(lines[6])))
+				}
+
+				serve(t, r, "PUT", "/singers/1", `{"name": "Alice", "country": "JP", "aliases": ["Ali, A"]}`)
+				rr = serve(t, r, "GET", "/admin/export?format=csv", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, `attachment; filename="catalog.csv"`, rr.Header().Get("Content-Disposition"))
+				lines = strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+				if assert.Len(t, lines, 8) {
+					assert.Equal(t, "type,id,name,stage_name,real_name,country,debut_year,aliases,external_ids,title,singer_id,artists,release_date,genres,label,format", lines[0])
+					assert.Equal(t, `singer,1,Alice,,,JP,,"[""Ali, A""]",,,,,,,,`, lines[1])
+					assert.Equal(t, `album,3,,,,,,,,Bella's 1st Album,,"[{""singer_id"":2,""role"":""primary""}]",,,,`, lines[7])
+				}
+
+				rr = serve(t, r, "GET", "/admin/export?format=xml", "")
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+			})
+		})
+	}
+
+	for name, newRouter := range emptyBackends {
+		t.Run(name, func(t *testing.T) {
+			// 書き出したデータを空のバックエンドに取り込むと同じデータになる
+			t.Run("RoundTrip", func(t *testing.T) {
+				src := asAdmin(api.NewRouter(api.WithAdminToken(testAdminToken)))
+				serve(t, src, "PUT", "/singers/2", `{"name": "Bella", "country": "GB", "debut_year": 2010, "external_ids": {"musicbrainz": "b-1"}}`)
+				serve(t, src, "POST", "/albums", `{"title": "Duet", "artists": [{"singer_id": 2, "role": "primary"}, {"singer_id": 3, "role": "featured"}],
+					"release_date": "2020-05", "genres": ["pop"], "format": "cd"}`)
+
+				for _, format := range []struct{ name, contentType string }{
+					{"ndjson", "application/x-ndjson"},
+					{"csv", "text/csv"},
+				} {
+					t.Run(format.name, func(t *testing.T) {
+						exported := serve(t, src, "GET", "/admin/export?format="+format.name, "")
+						r := newRouter(t)
+						rr := serveWithContentType(t, r, "POST", "/admin/import", format.contentType, exported.Body.String())
+						assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+						assert.JSONEq(t, `{"singers": 5, "albums": 4, "dry_run": false}`, rr.Body.String())
+
+						// 作成・更新日時は取り込んだときのものになる
+						expected := serve(t, src, "GET", "/admin/export", "")
+						actual := serve(t, r, "GET", "/admin/export", "")
+						assert.JSONEq(t, withoutTimestamps(t, expected.Body.Bytes()), withoutTimestamps(t, actual.Body.Bytes()))
+					})
+				}
+			})
+
+			// 歌手を外したアルバムや、論理削除した歌手が参加しているアルバムも書き出して取り込める
+			t.Run("RoundTripDeletedSingers", func(t *testing.T) {
+				singerRepo, albumRepo := memorydb.NewSingerRepository(), memorydb.NewAlbumRepository()
+				src := asAdmin(api.NewRouter(api.WithRepositories(singerRepo, albumRepo), api.WithAdminToken(testAdminToken),
+					api.WithSingerDeletePolicy(service.SingerDeleteOrphan)))
+				restrict := api.NewRouter(api.WithRepositories(singerRepo, albumRepo))
+				for _, body := range []string{`{"id": 1, "name": "Alice"}`, `{"id": 2, "name": "Bella"}`, `{"id": 3, "name": "Chris"}`} {
+					assert.Equal(t, http.StatusCreated, serve(t, src, "POST", "/singers", body).Code)
+				}
+				for _, body := range []string{
+					`{"id": 1, "title": "Solo", "singer_id": 1}`,
+					`{"id": 2, "title": "Duet", "artists": [{"singer_id": 1, "role": "primary"}, {"singer_id": 2, "role": "featured"}]}`,
+					`{"id": 3, "title": "Trio", "artists": [{"singer_id": 2, "role": "primary"}, {"singer_id": 3, "role": "featured"}]}`,
+				} {
+					assert.Equal(t, http.StatusCreated, serve(t, src, "POST", "/albums", body).Code)
+				}
+				// orphan で削除した歌手はアルバムから外れ、メインの歌手がいないアルバムが残る
+				assert.Equal(t, http.StatusNoContent, serve(t, src, "DELETE", "/singers/1", "").Code)
+				// 論理削除したアルバムを戻すと、論理削除した歌手が参加したままになる
+				assert.Equal(t, http.StatusNoContent, serve(t, restrict, "DELETE", "/albums/3", "").Code)
+				assert.Equal(t, http.StatusNoContent, serve(t, restrict, "DELETE", "/singers/3", "").Code)
+				assert.Equal(t, http.StatusOK, serve(t, restrict, "POST", "/albums/3:restore", "").Code)
+
+				for _, format := range []struct{ name, contentType string }{
+					{"ndjson", "application/x-ndjson"},
+					{"csv", "text/csv"},
+				} {
+					t.Run(format.name, func(t *testing.T) {
+						exported := serve(t, src, "GET", "/admin/export?format="+format.name, "")
+						r := newRouter(t)
+						rr := serveWithContentType(t, r, "POST", "/admin/import", format.contentType, exported.Body.String())
+						assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+						assert.JSONEq(t, `{"singers": 1, "albums": 3, "dry_run": false}`, rr.Body.String())
+
+						actual := serve(t, r, "GET", "/admin/export", "")
+						assert.JSONEq(t, `{
+							"singers": [{"id": 2, "name": "Bella"}],
+							"albums": [
+								{"id": 1, "title": "Solo", "singer_id": 0, "artists": []},
+								{"id": 2, "title": "Duet", "singer_id": 0, "artists": [{"singer_id": 2, "role": "featured"}]},
+								{"id": 3, "title": "Trio", "singer_id": 2, "artists": [{"singer_id": 2, "role": "primary"}]}
+							]
+						}`, withoutTimestamps(t, actual.Body.Bytes()))
+					})
+				}
+			})
+
+			// 問題がある場合は行ごとのエラーを返し、何も登録しない
+			t.Run("Errors", func(t *testing.T) {
+				r := newRouter(t)
+				body := strings.Join([]string{
+					`{"singer": {"id": 1, "name": "Alice"}}`,
+					`{"singer": {"id": 1, "name": "Alicia"}}`,
+					``,
+					`{"singer": {"name": "Bella", "country": "Japan"}}`,
+					`{"album": {"id": 1, "title": "A", "artists": [{"singer_id": 1, "role": "primary"}, {"singer_id": 9, "role": "featured"}]}}`,
+					`{"album": {"id": 2, "title": "B", "singer_id": "1"}}`,
+					`{"track": {"title": "T"}}`,
+					`not json`,
+				}, "\n")
+				rr := serveWithContentType(t, r, "POST", "/admin/import", "application/x-ndjson", body)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				assert.Equal(t, map[string]string{
+					"4 singer.id":       "required",
+					"4 singer.country":  "invalid_format",
+					"6 album.singer_id": "invalid_type",
+					"7 track":           "unknown_field",
+					"8":                 "malformed",
+				}, lineErrors(t, rr.Body.Bytes()))
+
+				// 形式に問題がなければ、重複や参照先を確認する
+				body = strings.Join([]string{
+					`{"singer": {"id": 1, "name": "Alice"}}`,
+					`{"singer": {"id": 1, "name": "Alicia"}}`,
+					`{"singer": {"id": 2, "name": "Alice"}}`,
+					`{"album": {"id": 1, "title": "A", "artists": [{"singer_id": 1, "role": "primary"}, {"singer_id": 9, "role": "featured"}]}}`,
+				}, "\n")
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "application/x-ndjson", body)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				assert.Equal(t, map[string]string{
+					"2 singer.id":                  "duplicate",
+					"3 singer.name":                "duplicate",
+					"4 album.artists[1].singer_id": "not_found",
+				}, lineErrors(t, rr.Body.Bytes()))
+
+				rr = serve(t, r, "GET", "/admin/export", "")
+				assert.JSONEq(t, `{"singers": [], "albums": []}`, rr.Body.String())
+
+				// 登録済みのデータとの重複
+				serve(t, r, "POST", "/singers", `{"id": 1, "name": "Alice"}`)
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "application/x-ndjson", `{"singer": {"id": 1, "name": "Alice"}}`)
+				assert.Equal(t, map[string]string{
+					"1 singer.id":   "conflict",
+					"1 singer.name": "conflict",
+				}, lineErrors(t, rr.Body.Bytes()))
+
+				// 論理削除した歌手・アルバムとIDは重複できないが、名前と国は重複できる
+				serve(t, r, "POST", "/singers", `{"id": 5, "name": "Eve"}`)
+				serve(t, r, "POST", "/albums", `{"id": 5, "title": "E", "singer_id": 5}`)
+				serve(t, r, "DELETE", "/albums/5", "")
+				serve(t, r, "DELETE", "/singers/5", "")
+				body = strings.Join([]string{
+					`{"singer": {"id": 6, "name": "Fay"}}`,
+					`{"singer": {"id": 5, "name": "Eve"}}`,
+					`{"album": {"id": 5, "title": "E", "singer_id": 1}}`,
+				}, "\n")
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "application/x-ndjson", body)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				assert.Equal(t, map[string]string{
+					"2 singer.id": "conflict",
+					"3 album.id":  "conflict",
+				}, lineErrors(t, rr.Body.Bytes()))
+				rr = serve(t, r, "GET", "/singers/6", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "application/x-ndjson", `{"singer": {"id": 7, "name": "Eve"}}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			})
+
+			t.Run("CSV", func(t *testing.T) {
+				r := newRouter(t)
+				body := "type,id,name,country,title,artists,genres\n" +
+					"singer,1,Alice,JP,,,\n" +
+					"singer,2,Bella,,,,\n" +
+					`album,1,,,Duet,"[{""singer_id"":1,""role"":""primary""},{""singer_id"":2,""role"":""featured""}]","[""Pop""]"` + "\n"
+
+				// dry_run では確認だけ行う
+				rr := serveWithContentType(t, r, "POST", "/admin/import?dry_run=true", "text/csv", body)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				assert.JSONEq(t, `{"singers": 2, "albums": 1, "dry_run": true}`, rr.Body.String())
+				rr = serve(t, r, "GET", "/singers", "")
+				assert.JSONEq(t, `[]`, rr.Body.String())
+
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "text/csv", body)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serve(t, r, "GET", "/albums/1", "")
+				assert.JSONEq(t, `{"id": 1, "title": "Duet", "singer": {"id": 1, "name": "Alice", "country": "JP"}, "genres": ["pop"], "singers": [
+					{"id": 1, "name": "Alice", "country": "JP", "role": "primary"},
+					{"id": 2, "name": "Bella", "role": "featured"}
+				]}`, withoutTimestamps(t, rr.Body.Bytes()))
+
+				// 行ごとのエラー
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "text/csv",
+					"type,id,name,title,artists\nartist,3,Chris,,\nsinger,4,Daisy,Title,\nalbum,2,,T,[oops\n")
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				assert.Equal(t, map[string]string{
+					"2 type":    "invalid_value",
+					"3 title":   "unknown_field",
+					"4 artists": "invalid_format",
+				}, lineErrors(t, rr.Body.Bytes()))
+
+				rr = serveWithContentType(t, r, "POST", "/admin/import", "text/csv", "type,id,nickname\n")
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+			})
+		})
+	}
+
+	t.Run("UnsupportedMediaType", func(t *testing.T) {
+		rr := serveWithContentType(t, asAdmin(api.NewRouter(api.WithAdminToken(testAdminToken))), "POST", "/admin/import", "application/json", `{"singer": {"id": 9, "name": "Ivy"}}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+}
@@ -0,0 +1,152 @@
+package api_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// Content-Type を指定してリクエストを送信する
+func serveWithContentType(t *testing.T, r http.Handler, method, path, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// エラーの本文を簡潔に確認できるよう、従来の {"message": ...} 形式を要求する
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+// リクエストボディの厳密な読み込み
+func TestRequestBodyDecoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		response    string // 空の場合はボディを確認しない
+	}{
+		{
+			name: "Valid", method: "POST", path: "/singers", contentType: "application/json",
+			body: `{"name": "Frank"}`, status: http.StatusCreated,
+		},
+		{
+			name: "Charset", method: "POST", path: "/singers", contentType: "application/json; charset=UTF-8",
+			body: `{"name": "Frank"}`, status: http.StatusCreated,
+		},
+		{
+			name: "NoContentType", method: "POST", path: "/singers", contentType: "",
+			body: `{"name": "Frank"}`, status: http.StatusUnsupportedMediaType,
+			response: `{"message": "Content-Type must be application/json"}`,
+		},
+		{
+			name: "FormContentType", method: "POST", path: "/albums", contentType: "application/x-www-form-urlencoded",
+			body: `{"title": "T", "singer_id": 1}`, status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "EmptyBody", method: "POST", path: "/singers", contentType: "application/json",
+			body: ``, status: http.StatusBadRequest,
+			response: `{"message": "request body is required"}`,
+		},
+		{
+			// 以前は nil ポインタの参照で panic していた
+			name: "Null", method: "POST", path: "/albums", contentType: "application/json",
+			body: `null`, status: http.StatusBadRequest,
+			response: `{"message": "request body must be a JSON object"}`,
+		},
+		{
+			name: "Array", method: "PUT", path: "/singers/1", contentType: "application/json",
+			body: `[{"name": "Frank"}]`, status: http.StatusBadRequest,
+		},
+		{
+			name: "TrailingData", method: "POST", path: "/singers", contentType: "application/json",
+			body: `{"name": "Frank"} {"name": "Grace"}`, status: http.StatusBadRequest,
+			response: `{"message": "request body must contain a single JSON object"}`,
+		},
+		{
+			name: "Malformed", method: "POST", path: "/singers", contentType: "application/json",
+			body: `{"name": "Frank",}`, status: http.StatusBadRequest,
+			response: `{"message": "malformed JSON at offset 18"}`,
+		},
+		{
+			name: "Truncated", method: "POST", path: "/singers", contentType: "application/json",
+			body: `{"name": "Frank"`, status: http.StatusBadRequest,
+		},
+		{
+			name: "UnknownField", method: "POST", path: "/albums", contentType: "application/json",
+			body: `{"title": "T", "singer_id": 1, "year": 2024}`, status: http.StatusBadRequest,
+			response: `{"message": "invalid request body", "errors": [{"field": "year", "code": "unknown_field", "message": "unknown field"}]}`,
+		},
+		{
+			name: "InvalidType", method: "POST", path: "/albums", contentType: "application/json",
+			body: `{"title": "T", "singer_id": "1"}`, status: http.StatusBadRequest,
+			response: `{"message": "invalid request body", "errors": [{"field": "singer_id", "code": "invalid_type", "message": "must be an integer, got string"}]}`,
+		},
+		{
+			name: "MergePatch", method: "PATCH", path: "/singers/1", contentType: "application/merge-patch+json",
+			body: `{"name": "Alicia"}`, status: http.StatusOK,
+		},
+		{
+			// application/merge-patch+json は PATCH だけで受け付ける
+			name: "MergePatchOnPost", method: "POST", path: "/singers", contentType: "application/merge-patch+json",
+			body: `{"name": "Frank"}`, status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "PatchNull", method: "PATCH", path: "/albums/1", contentType: "application/merge-patch+json",
+			body: `null`, status: http.StatusBadRequest,
+		},
+		{
+			// パッチも POST, PUT と同じく未知の項目を受け付けない
+			name: "PatchUnknownField", method: "PATCH", path: "/albums/1", contentType: "application/merge-patch+json",
+			body: `{"titel": "x"}`, status: http.StatusBadRequest,
+			response: `{"message": "invalid request body", "errors": [{"field": "titel", "code": "unknown_field", "message": "unknown field"}]}`,
+		},
+		{
+			name: "PatchUnknownNestedField", method: "PATCH", path: "/albums/1", contentType: "application/merge-patch+json",
+			body: `{"artists": [{"singer_id": 2, "role": "primary", "name": "Bella"}]}`, status: http.StatusBadRequest,
+			response: `{"message": "invalid request body", "errors": [{"field": "name", "code": "unknown_field", "message": "unknown field"}]}`,
+		},
+		{
+			name: "PatchReadOnly", method: "PATCH", path: "/singers/1", contentType: "application/merge-patch+json",
+			body: `{"name": "Alicia", "created_at": "2000-01-01T00:00:00Z", "deleted_at": null}`, status: http.StatusBadRequest,
+			response: `{"message": "invalid request body", "errors": [
+				{"field": "created_at", "code": "read_only", "message": "is read-only"},
+				{"field": "deleted_at", "code": "read_only", "message": "is read-only"}
+			]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveWithContentType(t, api.NewRouter(), tt.method, tt.path, tt.contentType, tt.body)
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+			if tt.response != "" {
+				assert.JSONEq(t, tt.response, withoutTimestamps(t, rr.Body.Bytes()))
+			}
+		})
+	}
+
+	// 上限を超えるボディは 413
+	t.Run("TooLarge", func(t *testing.T) {
+		r := api.NewRouter(api.WithMaxBodyBytes(64))
+
+		rr := serveWithContentType(t, r, "POST", "/singers", "application/json", `{"name": "Frank"}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = serveWithContentType(t, r, "POST", "/singers", "application/json", `{"name": "`+strings.Repeat("a", 64)+`"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		assert.JSONEq(t, `{"message": "request body must not exceed 64 bytes"}`, withoutTimestamps(t, rr.Body.Bytes()))
+	})
+}
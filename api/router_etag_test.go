@@ -0,0 +1,170 @@
+package api_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 条件付きリクエストのヘッダー (If-Match / If-None-Match) を指定して送信する
+func serveConditional(t *testing.T, r http.Handler, method, path, header, tag, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var requestBody io.Reader
+	if body != "" {
+		requestBody = bytes.NewBufferString(body)
+	}
+	req, err := http.NewRequest(method, path, requestBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tag != "" {
+		req.Header.Set(header, tag)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+// ETag による楽観的排他制御と条件付き GET
+func TestETag(t *testing.T) {
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("ConditionalGet", func(t *testing.T) {
+				r := newRouter(t)
+
+				rr := serve(t, r, "GET", "/singers/1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, `"singer-1-v1"`, rr.Header().Get("ETag"))
+
+				// 変更がなければ 304 で本文を返さない
+				rr = serveConditional(t, r, "GET", "/singers/1", "If-None-Match", `"singer-1-v1"`, "")
+				assert.Equal(t, http.StatusNotModified, rr.Code)
+				assert.Equal(t, `"singer-1-v1"`, rr.Header().Get("ETag"))
+				assert.Empty(t, rr.Body.String())
+
+				// 弱い比較なので W/ 付きのタグも一致する
+				rr = serveConditional(t, r, "GET", "/singers/1", "If-None-Match", `W/"singer-1-v1"`, "")
+				assert.Equal(t, http.StatusNotModified, rr.Code)
+
+				// ほかのリソースのタグとは一致しない
+				rr = serveConditional(t, r, "GET", "/singers/2", "If-None-Match", `"singer-1-v1"`, "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				// 更新するとタグが変わる
+				rr = serve(t, r, "PUT", "/singers/1", `{"name": "Alicia"}`)
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, `"singer-1-v2"`, rr.Header().Get("ETag"))
+
+				rr = serveConditional(t, r, "GET", "/singers/1", "If-None-Match", `"singer-1-v0", "singer-1-v1"`, "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `{"id": 1, "name": "Alicia"}`, withoutTimestamps(t, rr.Body.Bytes()))
+			})
+
+			// 歌手を含むアルバムのタグは歌手の更新でも変わる
+			t.Run("AlbumWithSinger", func(t *testing.T) {
+				r := newRouter(t)
+
+				rr := serve(t, r, "GET", "/albums/1", "")
+				before := rr.Header().Get("ETag")
+				assert.Regexp(t, `^"album-1-v1\+[0-9a-f]{8}"$`, before)
+
+				serve(t, r, "PATCH", "/singers/1", `{"name": "Alicia"}`)
+				rr = serveConditional(t, r, "GET", "/albums/1", "If-None-Match", before, "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Regexp(t, `^"album-1-v1\+[0-9a-f]{8}"$`, rr.Header().Get("ETag"))
+				assert.NotEqual(t, before, rr.Header().Get("ETag"))
+
+				// If-Match ではアルバムのタグの部分と比較するので、歌手の更新で書き込みは失敗しない
+				rr = serveConditional(t, r, "PATCH", "/albums/1", "If-Match", before, `{"title": "New Title"}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				assert.Equal(t, `"album-1-v2"`, rr.Header().Get("ETag"))
+
+				// アルバムを更新した後は一致しない
+				rr = serveConditional(t, r, "PATCH", "/albums/1", "If-Match", before, `{"title": "Newer Title"}`)
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+			})
+
+			// 同じバージョンをもとにした2つ目の書き込みは 412
+			t.Run("LostUpdate", func(t *testing.T) {
+				r := newRouter(t)
+
+				rr := serveConditional(t, r, "PUT", "/albums/1", "If-Match", `"album-1-v1"`, `{"title": "First", "singer_id": 1}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				assert.Equal(t, `"album-1-v2"`, rr.Header().Get("ETag"))
+
+				rr = serveConditional(t, r, "PUT", "/albums/1", "If-Match", `"album-1-v1"`, `{"title": "Second", "singer_id": 1}`)
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+				assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+
+				rr = serveConditional(t, r, "PATCH", "/albums/1", "If-Match", `"album-1-v1"`, `{"title": "Second"}`)
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+				rr = serve(t, r, "GET", "/albums/1", "")
+				assert.JSONEq(t, `{"id": 1, "title": "First", "singer": {"id": 1, "name": "Alice"}, "singers": [{"id": 1, "name": "Alice", "role": "primary"}]}`, withoutTimestamps(t, rr.Body.Bytes()))
+			})
+
+			t.Run("Delete", func(t *testing.T) {
+				r := newRouter(t)
+
+				serve(t, r, "PUT", "/singers/5", `{"name": "Ellie"}`)
+				rr := serveConditional(t, r, "DELETE", "/singers/5", "If-Match", `"singer-5-v1"`, "")
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+				rr = serveConditional(t, r, "DELETE", "/singers/5", "If-Match", `"singer-5-v2"`, "")
+				assert.Equal(t, http.StatusNoContent, rr.Code)
+
+				// 存在しない場合はバージョンより先に 404
+				rr = serveConditional(t, r, "DELETE", "/singers/5", "If-Match", `"singer-5-v2"`, "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+			})
+
+			t.Run("IfMatchHeader", func(t *testing.T) {
+				r := newRouter(t)
+
+				// "*" は存在するリソースすべてに一致する
+				rr := serveConditional(t, r, "PATCH", "/singers/2", "If-Match", `*`, `{"name": "Belle"}`)
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, `"singer-2-v2"`, rr.Header().Get("ETag"))
+
+				// If-Match は強い比較なので弱いタグは一致しない
+				rr = serveConditional(t, r, "PATCH", "/singers/2", "If-Match", `W/"singer-2-v2"`, `{"name": "Bell"}`)
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+				// タグ全体を比較するので、バージョンが同じでも別のタグは一致しない
+				for _, tag := range []string{`"2"`, `"singer-2-v2-anything"`, `"singer-2-v2+zzzzzzzz"`, `"album-2-v2"`, `"singer-3-v2"`} {
+					rr = serveConditional(t, r, "PATCH", "/singers/2", "If-Match", tag, `{"name": "Bell"}`)
+					assert.Equal(t, http.StatusPreconditionFailed, rr.Code, tag)
+				}
+				rr = serveConditional(t, r, "PUT", "/singers/3", "If-Match", `"singer-2-v2"`, `{"name": "Chris"}`)
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+				rr = serveConditional(t, r, "DELETE", "/albums/2", "If-Match", `"singer-2-v1"`, "")
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+				rr = serveConditional(t, r, "PATCH", "/singers/2", "If-Match", `2`, `{"name": "Bell"}`)
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				rr = serveConditional(t, r, "PATCH", "/singers/2", "If-Match", `"singer-2-v2", *`, `{"name": "Bell"}`)
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+				// タグの一覧はいずれかが一致すればよい
+				rr = serveConditional(t, r, "PATCH", "/singers/2", "If-Match", `"singer-2-v1", "singer-2-v2"`, `{"name": "Bell"}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serveConditional(t, r, "PUT", "/singers/2", "If-Match", `"singer-2-v1", W/"singer-2-v3", "singer-2-v3"`, `{"name": "Bella"}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serveConditional(t, r, "DELETE", "/singers/2", "If-Match", `"singer-2-v1", "singer-2-v2"`, "")
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+
+				// アルバムを含む表現のタグも一覧に含められる
+				tag := serve(t, r, "GET", "/albums/3", "").Header().Get("ETag")
+				rr = serveConditional(t, r, "DELETE", "/albums/3", "If-Match", `"album-3-v0", `+tag, "")
+				assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+			})
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// 死活監視のレスポンス
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func decodeHealth(t *testing.T, body []byte) healthResponse {
+	t.Helper()
+	var res healthResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Healthz", func(t *testing.T) {
+		rr := serve(t, api.NewRouter(), "GET", "/healthz", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "ok", decodeHealth(t, rr.Body.Bytes()).Status)
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		db, _ := openTestDB(t)
+		rr := serve(t, api.NewRouter(api.WithSQLDB(db)), "GET", "/readyz", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, healthResponse{Status: "ok", Checks: map[string]string{"singers": "ok", "albums": "ok", "tracks": "ok"}}, decodeHealth(t, rr.Body.Bytes()))
+	})
+
+	// データベースに接続できない場合
+	t.Run("BackendDown", func(t *testing.T) {
+		db, _ := openTestDB(t)
+		r := api.NewRouter(api.WithSQLDB(db))
+		db.Close()
+
+		rr := serve(t, r, "GET", "/readyz", "")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, healthResponse{Status: "unavailable", Checks: map[string]string{"singers": "unavailable", "albums": "unavailable", "tracks": "unavailable"}}, decodeHealth(t, rr.Body.Bytes()))
+
+		// プロセス自体は応答できる
+		rr = serve(t, r, "GET", "/healthz", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	// シャットダウンを始めたら readyz だけ失敗させる
+	t.Run("ShuttingDown", func(t *testing.T) {
+		readiness := &api.Readiness{}
+		r := api.NewRouter(api.WithReadiness(readiness))
+
+		rr := serve(t, r, "GET", "/readyz", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		readiness.ShuttingDown()
+		rr = serve(t, r, "GET", "/readyz", "")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "shutting down", decodeHealth(t, rr.Body.Bytes()).Status)
+
+		// 処理中・後続のリクエストは引き続き処理する
+		rr = serve(t, r, "GET", "/singers/1", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
@@ -0,0 +1,224 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// レスポンスの歌手のIDを取り出す
+func singerIDs(t *testing.T, body []byte) []model.SingerID {
+	t.Helper()
+	var singers []*model.Singer
+	if err := json.Unmarshal(body, &singers); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]model.SingerID, 0, len(singers))
+	for _, s := range singers {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// GET /singers, GET /albums の並び替えとページング
+func TestListPagination(t *testing.T) {
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			// 既定ではID昇順
+			t.Run("DefaultOrder", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, []model.SingerID{1, 2, 3, 4, 5}, singerIDs(t, rr.Body.Bytes()))
+				assert.Empty(t, rr.Header().Get("Link"))
+			})
+
+			// ページングのパラメータを指定しない場合は件数を制限しない
+			t.Run("NoLimitByDefault", func(t *testing.T) {
+				r := newRouter(t)
+				for i := 0; i < 100; i++ {
+					serve(t, r, "POST", "/singers", fmt.Sprintf(`{"name": "Singer %d"}`, i))
+				}
+				rr := serve(t, r, "GET", "/singers", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Len(t, singerIDs(t, rr.Body.Bytes()), 105)
+				assert.Empty(t, rr.Header().Get("Link"))
+
+				// offset だけを指定した場合は既定の件数で区切り、続きを案内する
+				rr = serve(t, r, "GET", "/singers?offset=1", "")
+				assert.Len(t, singerIDs(t, rr.Body.Bytes()), 100)
+				assert.Equal(t, `</singers?offset=101>; rel="next"`, rr.Header().Get("Link"))
+			})
+
+			t.Run("SortDesc", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers?sort=name&order=desc", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, []model.SingerID{5, 4, 3, 2, 1}, singerIDs(t, rr.Body.Bytes()))
+			})
+
+			t.Run("LimitOffset", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers?limit=2&offset=2", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, []model.SingerID{3, 4}, singerIDs(t, rr.Body.Bytes()))
+				assert.Equal(t, `</singers?limit=2&offset=4>; rel="next"`, rr.Header().Get("Link"))
+			})
+
+			// カーソルをたどるとすべての要素を重複なく取得できる
+			t.Run("Cursor", func(t *testing.T) {
+				r := newRouter(t)
+				var ids []model.SingerID
+				path := "/singers?limit=2&sort=name&order=desc"
+				for i := 0; i < 5 && path != ""; i++ {
+					rr := serve(t, r, "GET", path, "")
+					assert.Equal(t, http.StatusOK, rr.Code)
+					ids = append(ids, singerIDs(t, rr.Body.Bytes())...)
+
+					path = ""
+					if cursor := rr.Header().Get("X-Next-Cursor"); cursor != "" {
+						path = "/singers?limit=2&sort=name&order=desc&cursor=" + cursor
+					}
+				}
+				assert.Equal(t, []model.SingerID{5, 4, 3, 2, 1}, ids)
+			})
+
+			t.Run("AlbumSortByTitle", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/albums?sort=title&order=desc&limit=1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				var albums []*model.AlbumSinger
+				if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
+					t.Fatal(err)
+				}
+				assert.Len(t, albums, 1)
+				assert.Equal(t, "Bella's 1st Album", albums[0].Title)
+				assert.NotEmpty(t, rr.Header().Get("X-Next-Cursor"))
+			})
+		})
+	}
+
+	// 不正なパラメータは400 (ほかのエラーと同じく項目ごとの詳細を返す)
+	t.Run("InvalidParams", func(t *testing.T) {
+		r := backends()["memorydb"](t)
+		for path, expected := range map[string]string{
+			"/singers?limit=0":              "limit out_of_range",
+			"/singers?limit=abc":            "limit invalid_format",
+			"/singers?offset=-1":            "offset out_of_range",
+			"/singers?sort=title":           "sort invalid_value",
+			"/singers?order=up":             "order invalid_value",
+			"/singers?cursor=!!!":           "cursor invalid_format",
+			"/singers?offset=1&cursor=abc":  "cursor exclusive",
+			"/albums?sort=name":             "sort invalid_value",
+			"/singers/1/albums?limit=10000": "limit out_of_range",
+		} {
+			rr := serve(t, r, "GET", path, "")
+			assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"), path)
+			assert.Equal(t, expected, queryParamError(t, rr.Body.Bytes()), path)
+		}
+
+		rr := serveAccept(t, r, "/singers?limit=0", "")
+		assert.JSONEq(t, `{
+			"type": "/problems/invalid-request",
+			"title": "Invalid query parameter",
+			"status": 400,
+			"detail": "invalid query param: limit must be between 1 and 1000",
+			"instance": "/singers",
+			"request_id": "problem-test",
+			"errors": [{"field": "limit", "code": "out_of_range", "message": "must be between 1 and 1000"}]
+		}`, rr.Body.String())
+	})
+}
+
+// クエリパラメータのエラーのレスポンスから "項目名 コード" を取り出す
+func queryParamError(t *testing.T, body []byte) string {
+	t.Helper()
+	var problem struct {
+		Errors []struct {
+			Field string `json:"field"`
+			Code  string `json:"code"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &problem); err != nil {
+		t.Fatal(err)
+	}
+	if len(problem.Errors) != 1 {
+		return string(body)
+	}
+	return problem.Errors[0].Field + " " + problem.Errors[0].Code
+}
+
+// GET /singers, GET /albums の検索条件
+func TestListFilter(t *testing.T) {
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			// 名前の部分一致 (大文字小文字を区別しない)
+			t.Run("SingerName", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers?name=ELL", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, []model.SingerID{2, 5}, singerIDs(t, rr.Body.Bytes()))
+			})
+
+			t.Run("AlbumSingerID", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/albums?singer_id=1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				var albums []*model.AlbumSinger
+				if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
+					t.Fatal(err)
+				}
+				assert.Len(t, albums, 2)
+				for _, a := range albums {
+					assert.Equal(t, model.SingerID(1), a.Singer.ID)
+				}
+			})
+
+			// 複数の条件はすべて満たすものだけ
+			t.Run("AlbumTitleAndSinger", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/albums?singer_id=1&title_contains=2nd", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				var albums []*model.AlbumSinger
+				if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
+					t.Fatal(err)
+				}
+				assert.Len(t, albums, 1)
+				assert.Equal(t, model.AlbumID(2), albums[0].ID)
+			})
+
+			// 次のページのリンクにも検索条件が引き継がれる
+			t.Run("FilterWithPaging", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/albums?singer_id=1&limit=1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Contains(t, rr.Header().Get("Link"), "singer_id=1")
+			})
+		})
+	}
+
+	t.Run("InvalidParams", func(t *testing.T) {
+		r := asAdmin(backends(api.WithAdminToken(testAdminToken))["memorydb"](t))
+		for path, expected := range map[string]string{
+			"/albums?singer_id=abc":             "singer_id invalid_format",
+			"/albums?singer_id=0":               "singer_id out_of_range",
+			"/albums?format=cassette":           "format invalid_value",
+			"/albums?released_after=2024-13-01": "released_after invalid_format",
+			"/singers?include_deleted=maybe":    "include_deleted invalid_value",
+			"/singers?updated_since=yesterday":  "updated_since invalid_format",
+		} {
+			rr := serve(t, r, "GET", path, "")
+			assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+			assert.Equal(t, expected, queryParamError(t, rr.Body.Bytes()), path)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// ログの出力先をバッファに差し替え、JSON の各行を返す関数を作成する
+func captureLogs(t *testing.T) func() []map[string]interface{} {
+	t.Helper()
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	return func() []map[string]interface{} {
+		var lines []map[string]interface{}
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			line := map[string]interface{}{}
+			if err := dec.Decode(&line); err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+}
+
+// リクエストごとに1行のログを出力し、リクエストIDをエラーログと共有する
+func TestRequestLogging(t *testing.T) {
+	t.Run("PropagateRequestID", func(t *testing.T) {
+		logs := captureLogs(t)
+		r := api.NewRouter()
+
+		req, err := http.NewRequest("GET", "/singers/999", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Request-ID", "test-request-1")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "test-request-1", rr.Header().Get("X-Request-ID"))
+
+		lines := logs()
+		if assert.Len(t, lines, 2) {
+			// エラーログ
+			assert.Equal(t, "error response", lines[0]["msg"])
+			assert.Equal(t, "test-request-1", lines[0]["request_id"])
+			// アクセスログ
+			assert.Equal(t, "request", lines[1]["msg"])
+			assert.Equal(t, "test-request-1", lines[1]["request_id"])
+			assert.Equal(t, "GET", lines[1]["method"])
+			assert.Equal(t, "/singers/{id:[1-9][0-9]*}", lines[1]["route"])
+			assert.Equal(t, "/singers/999", lines[1]["path"])
+			assert.Equal(t, float64(http.StatusNotFound), lines[1]["status"])
+			assert.Equal(t, float64(rr.Body.Len()), lines[1]["bytes"])
+			assert.Contains(t, lines[1], "latency")
+		}
+	})
+
+	// ヘッダがない場合や不正な場合は新しく払い出す
+	t.Run("GenerateRequestID", func(t *testing.T) {
+		logs := captureLogs(t)
+		r := api.NewRouter()
+
+		req, err := http.NewRequest("GET", "/singers", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Request-ID", "contains space")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		id := rr.Header().Get("X-Request-ID")
+		assert.Len(t, id, 32)
+		lines := logs()
+		if assert.Len(t, lines, 1) {
+			assert.Equal(t, id, lines[0]["request_id"])
+			assert.Equal(t, float64(http.StatusOK), lines[0]["status"])
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// リクエスト数・処理時間とリポジトリの件数を公開する
+func TestMetrics(t *testing.T) {
+	r := api.NewRouter()
+
+	serve(t, r, "GET", "/singers/1", "")
+	serve(t, r, "GET", "/singers/2", "")
+	serve(t, r, "GET", "/singers/999", "")
+	serve(t, r, "DELETE", "/albums/1", "")
+
+	rr := serve(t, r, "GET", "/metrics", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+
+	// パスパラメータではなくルートのテンプレートで集計する
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/singers/{id:[1-9][0-9]*}",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/singers/{id:[1-9][0-9]*}",status="404"} 1`)
+	assert.Contains(t, body, `http_requests_total{method="DELETE",route="/albums/{id:[1-9][0-9]*}",status="204"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/singers/{id:[1-9][0-9]*}",status="200"} 2`)
+
+	// 件数は取得時点の値
+	assert.Contains(t, body, "app_singers 5\n")
+	assert.Contains(t, body, "app_albums 2\n")
+}
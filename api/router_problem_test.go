@@ -0,0 +1,101 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// Accept とリクエストIDを指定して GET リクエストを送信する
+func serveAccept(t *testing.T, r http.Handler, path, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	req.Header.Set("X-Request-ID", "problem-test")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+// エラーは RFC 7807 の problem+json で返す
+func TestProblemDetails(t *testing.T) {
+	t.Run("NotFound", func(t *testing.T) {
+		rr := serveAccept(t, api.NewRouter(), "/singers/999", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Not Found",
+			"status": 404,
+			"detail": "singer 999: not found",
+			"instance": "/singers/999",
+			"request_id": "problem-test"
+		}`, rr.Body.String())
+	})
+
+	// strconv のエラーなどの内部の文字列は返さない
+	t.Run("InvalidPathParam", func(t *testing.T) {
+		rr := serveAccept(t, api.NewRouter(), "/singers/99999999999999999999", "")
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Bad Request",
+			"status": 400,
+			"detail": "invalid path param: id must be a positive integer",
+			"instance": "/singers/99999999999999999999",
+			"request_id": "problem-test"
+		}`, rr.Body.String())
+	})
+
+	// サーバーエラーの詳細はログにだけ出力する
+	t.Run("InternalError", func(t *testing.T) {
+		db, _ := openTestDB(t)
+		r := api.NewRouter(api.WithSQLDB(db))
+		db.Close()
+
+		rr := serveAccept(t, r, "/singers", "")
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.JSONEq(t, `{
+			"type": "about:blank",
+			"title": "Internal Server Error",
+			"status": 500,
+			"detail": "internal server error",
+			"instance": "/singers",
+			"request_id": "problem-test"
+		}`, rr.Body.String())
+		assert.NotContains(t, rr.Body.String(), "sql")
+	})
+
+	// application/problem+json を受け付けないクライアントには従来の形式で返す
+	t.Run("Negotiation", func(t *testing.T) {
+		tests := []struct {
+			accept      string
+			contentType string
+		}{
+			{accept: "", contentType: "application/problem+json"},
+			{accept: "*/*", contentType: "application/problem+json"},
+			{accept: "application/problem+json", contentType: "application/problem+json"},
+			{accept: "application/json, application/problem+json", contentType: "application/problem+json"},
+			{accept: "text/html", contentType: "application/problem+json"},
+			{accept: "application/json", contentType: "application/json"},
+			{accept: "application/*;q=0.5, application/json", contentType: "application/json"},
+			{accept: "application/json, application/problem+json;q=0.5", contentType: "application/json"},
+		}
+		for _, tt := range tests {
+			rr := serveAccept(t, api.NewRouter(), "/singers/999", tt.accept)
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			assert.Equal(t, tt.contentType, rr.Header().Get("Content-Type"), "Accept: %s", tt.accept)
+			if tt.contentType == "application/json" {
+				assert.JSONEq(t, `{"message": "singer 999: not found"}`, withoutTimestamps(t, rr.Body.Bytes()))
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// アルバムの発売日・ジャンル・レーベル・形態
+func TestAlbumRelease(t *testing.T) {
+	// アルバム一覧のIDを取得する
+	albumIDs := func(t *testing.T, r http.Handler, path string) []int {
+		t.Helper()
+		rr := serve(t, r, "GET", path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s returned %d: %s", path, rr.Code, rr.Body.String())
+		}
+		var albums []struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &albums); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0, len(albums))
+		for _, a := range albums {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("CRUD", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/albums", `{"title": "Live", "singer_id": 2,
+					"release_date": "2021-03", "genres": [" Pop ", "J-Rock"], "label": " Pulse Records ", "format": "CD"}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				assert.JSONEq(t, `{"id": 4, "title": "Live", "singer_id": 2, "artists": [{"singer_id": 2, "role": "primary"}],
+					"release_date": "2021-03", "genres": ["pop", "j-rock"], "label": "Pulse Records", "format": "cd"}`,
+					withoutTimestamps(t, rr.Body.Bytes()))
+
+				rr = serve(t, r, "GET", "/albums/4", "")
+				assert.JSONEq(t, `{"id": 4, "title": "Live", "singer": {"id": 2, "name": "Bella"}, "singers": [{"id": 2, "name": "Bella", "role": "primary"}],
+					"release_date": "2021-03", "genres": ["pop", "j-rock"], "label": "Pulse Records", "format": "cd"}`,
+					withoutTimestamps(t, rr.Body.Bytes()))
+
+				// null を指定した項目は削除される
+				rr = serve(t, r, "PATCH", "/albums/4", `{"genres": null, "label": null, "release_date": "2021-03-10"}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serve(t, r, "GET", "/albums/4", "")
+				assert.JSONEq(t, `{"id": 4, "title": "Live", "singer": {"id": 2, "name": "Bella"}, "singers": [{"id": 2, "name": "Bella", "role": "primary"}],
+					"release_date": "2021-03-10", "format": "cd"}`,
+					withoutTimestamps(t, rr.Body.Bytes()))
+			})
+
+			t.Run("Validation", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/albums", `{"title": "T", "singer_id": 1,
+					"release_date": "2021-3-1", "genres": ["pop", "POP", " "], "format": "cassette"}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				var problem struct {
+					Errors []struct {
+						Field string `json:"field"`
+						Code  string `json:"code"`
+					} `json:"errors"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+					t.Fatal(err)
+				}
+				codes := map[string]string{}
+				for _, e := range problem.Errors {
+					codes[e.Field] = e.Code
+				}
+				assert.Equal(t, map[string]string{
+					"release_date": "invalid_format",
+					"genres[1]":    "duplicate",
+					"genres[2]":    "required",
+					"format":       "invalid_value",
+				}, codes)
+
+				rr = serve(t, r, "POST", "/albums", `{"title": "T", "singer_id": 1, "release_date": "2021-02-30"}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			})
+
+			t.Run("Filter", func(t *testing.T) {
+				r := newRouter(t)
+				for _, body := range []string{
+					`{"title": "A", "singer_id": 1, "release_date": "2019-12-31", "genres": ["rock"], "format": "vinyl"}`,
+					`{"title": "B", "singer_id": 1, "release_date": "2020", "genres": ["pop", "rock"], "format": "cd"}`,
+					`{"title": "C", "singer_id": 2, "release_date": "2020-06", "genres": ["pop"], "format": "digital"}`,
+					`{"title": "D", "singer_id": 2, "release_date": "2021-01-15", "genres": ["jazz"]}`,
+				} {
+					if rr := serve(t, r, "POST", "/albums", body); rr.Code != http.StatusCreated {
+						t.Fatalf("POST /albums: %d %s", rr.Code, rr.Body.String())
+					}
+				}
+
+				// 年や年月だけの発売日は期間の最初の日として比べる
+				assert.Equal(t, []int{5, 6, 7}, albumIDs(t, r, "/albums?released_after=2020-01-01"))
+				assert.Equal(t, []int{4, 5}, albumIDs(t, r, "/albums?released_before=2020-05-31"))
+				assert.Equal(t, []int{6}, albumIDs(t, r, "/albums?released_after=2020-02-01&released_before=2020-12-31"))
+				assert.Equal(t, []int{5, 6}, albumIDs(t, r, "/albums?released_after=2020-01-01&genre=Pop"))
+				assert.Equal(t, []int{4, 5}, albumIDs(t, r, "/albums?genre=rock"))
+				assert.Equal(t, []int{4}, albumIDs(t, r, "/albums?format=vinyl"))
+				assert.Equal(t, []int{6, 7}, albumIDs(t, r, "/albums?singer_id=2&released_after=2020-01-01"))
+
+				for _, query := range []string{"released_after=2020", "released_before=yesterday", "format=cassette"} {
+					rr := serve(t, r, "GET", "/albums?"+query, "")
+					assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// GET /singers/{id}/albums と POST /singers/{id}/albums のテスト
+func TestSingerAlbums(t *testing.T) {
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Get", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers/1/albums", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				var albums []*model.Album
+				if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &albums); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, []*model.Album{
+					{ID: 1, Title: "Alice's 1st Album", SingerID: 1, Artists: soloArtists(1)},
+					{ID: 2, Title: "Alice's 2nd Album", SingerID: 1, Artists: soloArtists(1)},
+				}, albums)
+			})
+
+			// アルバムのない歌手は空配列
+			t.Run("GetEmpty", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers/3/albums", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `[]`, withoutTimestamps(t, rr.Body.Bytes()))
+			})
+
+			t.Run("GetUnknownSinger", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "GET", "/singers/999/albums", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+			})
+
+			// singer_id はパスから設定される
+			t.Run("Post", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/singers/3/albums", `{"title": "Chris 1st"}`)
+				assert.Equal(t, http.StatusCreated, rr.Code)
+				assert.Equal(t, "/albums/4", rr.Header().Get("Location"))
+
+				rr = serve(t, r, "GET", "/singers/3/albums", "")
+				var albums []*model.Album
+				if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &albums); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, []*model.Album{{ID: 4, Title: "Chris 1st", SingerID: 3, Artists: soloArtists(3)}}, albums)
+			})
+
+			t.Run("PostSingerIDMismatch", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/singers/3/albums", `{"title": "Chris 1st", "singer_id": 1}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			})
+
+			t.Run("PostUnknownSinger", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/singers/999/albums", `{"title": "Nobody's 1st"}`)
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+			})
+
+			// 歌手の付け替え後はインデックスも更新される
+			t.Run("AfterUpdate", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "PATCH", "/albums/2", `{"singer_id": 2}`)
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				rr = serve(t, r, "GET", "/singers/2/albums?sort=id", "")
+				var albums []*model.Album
+				if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &albums); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, []*model.Album{
+					{ID: 2, Title: "Alice's 2nd Album", SingerID: 2, Artists: soloArtists(2)},
+					{ID: 3, Title: "Bella's 1st Album", SingerID: 2, Artists: soloArtists(2)},
+				}, albums)
+			})
+		})
+	}
+}
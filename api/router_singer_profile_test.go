@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 歌手のプロフィールと別名
+func TestSingerProfile(t *testing.T) {
+	// 歌手の一覧のIDを取得する
+	singerIDs := func(t *testing.T, r http.Handler, path string) []int {
+		t.Helper()
+		rr := serve(t, r, "GET", path, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET %s returned %d: %s", path, rr.Code, rr.Body.String())
+		}
+		var singers []struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &singers); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0, len(singers))
+		for _, s := range singers {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("CRUD", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/singers", `{"name": "Frank", "stage_name": "DJ Frank", "real_name": " Frank Ocean ",
+					"country": "us", "debut_year": 2005, "external_ids": {"musicbrainz": "abc-123"}, "aliases": ["Lonny", "F"]}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				expected := `{"id": 6, "name": "Frank", "stage_name": "DJ Frank", "real_name": "Frank Ocean",
+					"country": "US", "debut_year": 2005, "external_ids": {"musicbrainz": "abc-123"}, "aliases": ["Lonny", "F"]}`
+				assert.JSONEq(t, expected, withoutTimestamps(t, rr.Body.Bytes()))
+				rr = serve(t, r, "GET", "/singers/6", "")
+				assert.JSONEq(t, expected, withoutTimestamps(t, rr.Body.Bytes()))
+
+				// null を指定した項目は削除される
+				rr = serve(t, r, "PATCH", "/singers/6", `{"stage_name": null, "external_ids": null, "aliases": ["Lonny"]}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serve(t, r, "GET", "/singers/6", "")
+				assert.JSONEq(t, `{"id": 6, "name": "Frank", "real_name": "Frank Ocean", "country": "US", "debut_year": 2005, "aliases": ["Lonny"]}`,
+					withoutTimestamps(t, rr.Body.Bytes()))
+
+				// 既存の歌手はプロフィールの項目を持たない
+				rr = serve(t, r, "GET", "/singers/1", "")
+				assert.JSONEq(t, `{"id": 1, "name": "Alice"}`, withoutTimestamps(t, rr.Body.Bytes()))
+			})
+
+			// 名前の検索は別名も対象にする
+			t.Run("SearchAliases", func(t *testing.T) {
+				r := newRouter(t)
+				serve(t, r, "PUT", "/singers/3", `{"name": "Chris", "aliases": ["Lady Lia"]}`)
+
+				assert.Equal(t, []int{1, 3}, singerIDs(t, r, "/singers?name=LI"))
+				assert.Equal(t, []int{3}, singerIDs(t, r, "/singers?name=lady"))
+			})
+
+			t.Run("Validation", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/singers", `{"name": "Frank", "country": "USA", "debut_year": 99,
+					"external_ids": {"MusicBrainz": "x", "spotify": " "}, "aliases": ["F", " F "]}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				var problem struct {
+					Errors []struct {
+						Field string `json:"field"`
+						Code  string `json:"code"`
+					} `json:"errors"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+					t.Fatal(err)
+				}
+				codes := map[string]string{}
+				for _, e := range problem.Errors {
+					codes[e.Field] = e.Code
+				}
+				assert.Equal(t, map[string]string{
+					"country":                  "invalid_format",
+					"debut_year":               "out_of_range",
+					"external_ids.MusicBrainz": "invalid_format",
+					"external_ids.spotify":     "required",
+					"aliases[1]":               "duplicate",
+				}, codes)
+			})
+
+			// 名前と国の組は一意
+			t.Run("Unique", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/singers", `{"name": "Alice"}`)
+				assert.Equal(t, http.StatusConflict, rr.Code)
+				rr = serve(t, r, "POST", "/singers", `{"name": "Alice", "country": "JP"}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				rr = serve(t, r, "POST", "/singers", `{"name": "Alice", "country": "jp"}`)
+				assert.Equal(t, http.StatusConflict, rr.Code)
+
+				// 更新でも重複させられない (自分自身とは重複しない)
+				rr = serve(t, r, "PUT", "/singers/2", `{"name": "Alice"}`)
+				assert.Equal(t, http.StatusConflict, rr.Code)
+				rr = serve(t, r, "PUT", "/singers/1", `{"name": "Alice", "debut_year": 2010}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+				// 論理削除した歌手とは重複してよいが、重複する場合は復元できない
+				serve(t, r, "DELETE", "/singers/5", "")
+				rr = serve(t, r, "PUT", "/singers/4", `{"name": "Ellen"}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				rr = serve(t, r, "POST", "/singers/5:restore", "")
+				assert.Equal(t, http.StatusConflict, rr.Code)
+			})
+		})
+	}
+}
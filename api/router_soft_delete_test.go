@@ -0,0 +1,93 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// 論理削除と復元
+func TestSoftDelete(t *testing.T) {
+	for name, newRouter := range backends(api.WithAdminToken(testAdminToken)) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Album", func(t *testing.T) {
+				r := asAdmin(newRouter(t))
+
+				rr := serve(t, r, "DELETE", "/albums/2", "")
+				assert.Equal(t, http.StatusNoContent, rr.Code)
+
+				// 通常の取得・一覧からは見えない
+				rr = serve(t, r, "GET", "/albums/2", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+				rr = serve(t, r, "GET", "/singers/1/albums", "")
+				assert.JSONEq(t, `[{"id": 1, "title": "Alice's 1st Album", "singer_id": 1, "artists": [{"singer_id": 1, "role": "primary"}]}]`, withoutTimestamps(t, rr.Body.Bytes()))
+				rr = serve(t, r, "PUT", "/albums/2", `{"title": "T", "singer_id": 1}`)
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+
+				// include_deleted を指定すると削除日時付きで返す
+				rr = serve(t, r, "GET", "/albums?include_deleted=true&singer_id=1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				var albums []struct {
+					ID        int     `json:"id"`
+					DeletedAt *string `json:"deleted_at"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &albums); err != nil {
+					t.Fatal(err)
+				}
+				if assert.Len(t, albums, 2) {
+					assert.Nil(t, albums[0].DeletedAt)
+					assert.NotNil(t, albums[1].DeletedAt)
+				}
+
+				// 削除したIDは再利用しない
+				rr = serve(t, r, "POST", "/albums", `{"id": 2, "title": "T", "singer_id": 1}`)
+				assert.Equal(t, http.StatusConflict, rr.Code)
+
+				// 復元すると元の内容で取得できる
+				rr = serve(t, r, "POST", "/albums/2:restore", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `{"id": 2, "title": "Alice's 2nd Album", "singer_id": 1, "artists": [{"singer_id": 1, "role": "primary"}]}`, withoutTimestamps(t, rr.Body.Bytes()))
+				assert.Equal(t, `"album-2-v3"`, rr.Header().Get("ETag"))
+				rr = serve(t, r, "GET", "/albums/2", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+
+				// 削除されていない場合はそのまま返す
+				rr = serve(t, r, "POST", "/albums/2:restore", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, `"album-2-v3"`, rr.Header().Get("ETag"))
+
+				rr = serve(t, r, "POST", "/albums/99:restore", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+			})
+
+			t.Run("Singer", func(t *testing.T) {
+				r := asAdmin(newRouter(t))
+
+				rr := serve(t, r, "DELETE", "/singers/5", "")
+				assert.Equal(t, http.StatusNoContent, rr.Code)
+				rr = serve(t, r, "GET", "/singers/5", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+				rr = serve(t, r, "GET", "/singers?name=ellen", "")
+				assert.JSONEq(t, `[]`, withoutTimestamps(t, rr.Body.Bytes()))
+				rr = serve(t, r, "GET", "/singers?name=ellen&include_deleted=1", "")
+				assert.Contains(t, rr.Body.String(), `"deleted_at"`)
+
+				// 削除した歌手を参照するアルバムは登録できない
+				rr = serve(t, r, "POST", "/albums", `{"title": "T", "singer_id": 5}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+				rr = serve(t, r, "POST", "/singers/5:restore", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `{"id": 5, "name": "Ellen"}`, withoutTimestamps(t, rr.Body.Bytes()))
+			})
+
+			t.Run("InvalidFlag", func(t *testing.T) {
+				rr := serve(t, asAdmin(newRouter(t)), "GET", "/singers?include_deleted=yes", "")
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+			})
+		})
+	}
+}
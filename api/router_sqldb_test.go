@@ -0,0 +1,142 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// テスト用のSQLiteデータベースを作成する
+func openTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db")
+	db, err := sqldb.Open(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, dsn
+}
+
+// SQLバックエンドでの歌手・アルバムの登録と取得
+func TestSQLDBSingerAlbum(t *testing.T) {
+	db, dsn := openTestDB(t)
+
+	t.Run("PostAndGet", func(t *testing.T) {
+		r := api.NewRouter(api.WithSQLDB(db))
+
+		// 歌手を追加
+		req, err := http.NewRequest("POST", "/singers", bytes.NewBufferString(`{"id": 1, "name": "Alice"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		// アルバムを追加
+		req, err = http.NewRequest("POST", "/albums", bytes.NewBufferString(`{"id": 1, "title": "Alice's 1st Album", "singer_id": 1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		// 歌手情報付きでアルバムを取得
+		req, err = http.NewRequest("GET", "/albums/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var album model.AlbumSinger
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, model.AlbumSinger{ID: 1, Title: "Alice's 1st Album", Singer: &model.Singer{ID: 1, Name: "Alice"}, Singers: soloCredit(1, "Alice")}, album)
+	})
+
+	// IDの払い出しと重複IDの拒否
+	t.Run("GeneratedID", func(t *testing.T) {
+		r := api.NewRouter(api.WithSQLDB(db))
+
+		req, err := http.NewRequest("POST", "/singers", bytes.NewBufferString(`{"name": "Bella"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, "/singers/2", rr.Header().Get("Location"))
+
+		// 削除したIDは再利用しない
+		req, err = http.NewRequest("DELETE", "/singers/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+
+		req, err = http.NewRequest("POST", "/singers", bytes.NewBufferString(`{"name": "Chris"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, "/singers/3", rr.Header().Get("Location"))
+
+		// 既存のIDを指定した場合は409
+		req, err = http.NewRequest("POST", "/singers", bytes.NewBufferString(`{"id": 1, "name": "Daisy"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	// 再起動してもデータが残っていることを確認
+	t.Run("Reopen", func(t *testing.T) {
+		db.Close()
+		reopened, err := sqldb.Open(context.Background(), dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reopened.Close()
+		r := api.NewRouter(api.WithSQLDB(reopened))
+
+		req, err := http.NewRequest("GET", "/singers", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var singers []*model.Singer
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &singers); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []*model.Singer{{ID: 1, Name: "Alice"}, {ID: 3, Name: "Chris"}}, singers)
+	})
+}
@@ -43,9 +43,9 @@ func TestAlbumGetAll(t *testing.T) {
 		log.Print("req: " + rr.Body.String())
 
 		expected := []*model.Album{
-			{ID: 1, Title: "Alice's 1st Album", SingerID: 1},
-			{ID: 2, Title: "Alice's 2nd Album", SingerID: 1},
-			{ID: 3, Title: "Bella's 1st Album", SingerID: 2},
+			{ID: 1, Title: "Alice's 1st Album", SingerID: 1, Artists: soloArtists(1)},
+			{ID: 2, Title: "Alice's 2nd Album", SingerID: 1, Artists: soloArtists(1)},
+			{ID: 3, Title: "Bella's 1st Album", SingerID: 2, Artists: soloArtists(2)},
 		}
 
 		var albums []*model.Album
@@ -84,7 +84,7 @@ func TestAlbumGet(t *testing.T) {
 
 		log.Print("req: " + rr.Body.String())
 
-		expected := &model.Album{ID: 1, Title: "Alice's 1st Album", SingerID: 1}
+		expected := &model.Album{ID: 1, Title: "Alice's 1st Album", SingerID: 1, Artists: soloArtists(1)}
 
 		var albums *model.Album
 		// レスポンスのボディを確認
@@ -182,7 +182,7 @@ func TestAlbumPost(t *testing.T) {
 			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 		}
 
-		expected := &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1}
+		expected := &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1, Artists: soloArtists(1)}
 
 		var albums *model.Album
 		// レスポンスのボディを確認
@@ -223,7 +223,7 @@ func TestAlbumPost(t *testing.T) {
 			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 		}
 
-		expected := &model.Album{ID: 1, Title: "Alice's 3rd Album", SingerID: 1}
+		expected := &model.Album{ID: 1, Title: "Alice's 3rd Album", SingerID: 1, Artists: soloArtists(1)}
 
 		var albums *model.Album
 		// レスポンスのボディを確認
@@ -0,0 +1,90 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// 作成・更新日時の記録と、更新日時での並び替え・絞り込み
+func TestTimestamps(t *testing.T) {
+	backends := map[string]func(t *testing.T, opts ...api.Option) http.Handler{
+		"memorydb": func(t *testing.T, opts ...api.Option) http.Handler { return api.NewRouter(opts...) },
+		"sqldb": func(t *testing.T, opts ...api.Option) http.Handler {
+			db, _ := openTestDB(t)
+			return api.NewRouter(append(opts, api.WithSQLDB(db))...)
+		},
+	}
+	for name, newRouter := range backends {
+		t.Run(name, func(t *testing.T) {
+			// 呼び出すたびに1分進む時計
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			clock := func() time.Time {
+				now = now.Add(time.Minute)
+				return now
+			}
+			r := newRouter(t, api.WithClock(clock))
+
+			type entity struct {
+				ID        int       `json:"id"`
+				CreatedAt time.Time `json:"created_at"`
+				UpdatedAt time.Time `json:"updated_at"`
+			}
+			decode := func(t *testing.T, body []byte, v interface{}) {
+				t.Helper()
+				if err := json.Unmarshal(body, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, name := range []string{"Xavier", "Yvonne", "Zelda"} {
+				rr := serve(t, r, "POST", "/singers", `{"name": "`+name+`"}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+			}
+			var found []entity
+			decode(t, serve(t, r, "GET", "/singers?name=xavier", "").Body.Bytes(), &found)
+			if !assert.Len(t, found, 1) {
+				return
+			}
+			created := found[0]
+
+			// 更新すると作成日時はそのままで更新日時だけが進む
+			rr := serve(t, r, "PUT", "/singers/"+strconv.Itoa(created.ID), `{"name": "Xavier"}`)
+			assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			var updated entity
+			decode(t, rr.Body.Bytes(), &updated)
+			assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+			assert.True(t, updated.UpdatedAt.After(created.UpdatedAt))
+
+			// 更新日時の降順では最後に更新した歌手が先頭になる
+			var singers []entity
+			decode(t, serve(t, r, "GET", "/singers?sort=updated_at&order=desc&limit=1", "").Body.Bytes(), &singers)
+			if assert.Len(t, singers, 1) {
+				assert.Equal(t, created.ID, singers[0].ID)
+			}
+
+			// updated_since 以降に更新された歌手だけを返す
+			since := updated.UpdatedAt.Format(time.RFC3339)
+			singers = nil
+			decode(t, serve(t, r, "GET", "/singers?updated_since="+since, "").Body.Bytes(), &singers)
+			if assert.Len(t, singers, 1) {
+				assert.Equal(t, updated, singers[0])
+			}
+
+			// アルバムの日時も同じ時計で記録する
+			rr = serve(t, r, "POST", "/albums", `{"title": "X1", "singer_id": `+strconv.Itoa(created.ID)+`}`)
+			assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+			var album entity
+			decode(t, rr.Body.Bytes(), &album)
+			assert.Equal(t, now, album.CreatedAt)
+
+			rr = serve(t, r, "GET", "/albums?updated_since=yesterday", "")
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
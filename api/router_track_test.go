@@ -0,0 +1,175 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// アルバムのトラックの登録・取得・更新・削除
+func TestTracks(t *testing.T) {
+	for name, newRouter := range backends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("CRUD", func(t *testing.T) {
+				r := newRouter(t)
+
+				// 番号を省略すると最後のトラックの次の番号になる
+				rr := serve(t, r, "POST", "/albums/1/tracks", `{"title": "Intro", "duration_seconds": 90}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				assert.Equal(t, "/albums/1/tracks/1", rr.Header().Get("Location"))
+				assert.JSONEq(t, `{"album_id": 1, "number": 1, "title": "Intro", "duration_seconds": 90}`, withoutTimestamps(t, rr.Body.Bytes()))
+				rr = serve(t, r, "POST", "/albums/1/tracks", `{"number": 5, "title": "Outro", "duration_seconds": 120, "isrc": "jp-ab0-24-00001"}`)
+				assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+				assert.JSONEq(t, `{"album_id": 1, "number": 5, "title": "Outro", "duration_seconds": 120, "isrc": "JPAB02400001"}`, withoutTimestamps(t, rr.Body.Bytes()))
+				rr = serve(t, r, "POST", "/albums/1/tracks", `{"title": "Bonus", "duration_seconds": 200}`)
+				assert.Equal(t, "/albums/1/tracks/6", rr.Header().Get("Location"))
+
+				// 使われている番号は上書きしない
+				rr = serve(t, r, "POST", "/albums/1/tracks", `{"number": 5, "title": "Dup", "duration_seconds": 1}`)
+				assert.Equal(t, http.StatusConflict, rr.Code)
+
+				// トラック番号順に返す
+				rr = serve(t, r, "GET", "/albums/1/tracks", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				var tracks []struct {
+					Number int `json:"number"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &tracks); err != nil {
+					t.Fatal(err)
+				}
+				if assert.Len(t, tracks, 3) {
+					assert.Equal(t, []int{1, 5, 6}, []int{tracks[0].Number, tracks[1].Number, tracks[2].Number})
+				}
+				rr = serve(t, r, "GET", "/albums/2/tracks", "")
+				assert.JSONEq(t, `[]`, rr.Body.String())
+
+				rr = serve(t, r, "GET", "/albums/1/tracks/5", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.Equal(t, `"track-1-5-v1"`, rr.Header().Get("ETag"))
+				rr = serveConditional(t, r, "GET", "/albums/1/tracks/5", "If-None-Match", `"track-1-5-v1"`, "")
+				assert.Equal(t, http.StatusNotModified, rr.Code)
+
+				// 更新
+				rr = serve(t, r, "PUT", "/albums/1/tracks/5", `{"title": "Outro (Live)", "duration_seconds": 150}`)
+				assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+				assert.Equal(t, `"track-1-5-v2"`, rr.Header().Get("ETag"))
+				assert.JSONEq(t, `{"album_id": 1, "number": 5, "title": "Outro (Live)", "duration_seconds": 150}`, withoutTimestamps(t, rr.Body.Bytes()))
+				rr = serveConditional(t, r, "PUT", "/albums/1/tracks/5", "If-Match", `"track-1-5-v1"`, `{"title": "T", "duration_seconds": 1}`)
+				assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+				rr = serve(t, r, "PUT", "/albums/1/tracks/2", `{"title": "T", "duration_seconds": 1}`)
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+
+				// 削除
+				rr = serve(t, r, "DELETE", "/albums/1/tracks/5", "")
+				assert.Equal(t, http.StatusNoContent, rr.Code)
+				rr = serve(t, r, "GET", "/albums/1/tracks/5", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+				rr = serve(t, r, "DELETE", "/albums/1/tracks/5", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+			})
+
+			t.Run("Validation", func(t *testing.T) {
+				r := newRouter(t)
+				rr := serve(t, r, "POST", "/albums/1/tracks", `{"album_id": 2, "title": " ", "duration_seconds": 0, "isrc": "ABC"}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+				var problem struct {
+					Errors []struct {
+						Field string `json:"field"`
+						Code  string `json:"code"`
+					} `json:"errors"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+					t.Fatal(err)
+				}
+				codes := map[string]string{}
+				for _, e := range problem.Errors {
+					codes[e.Field] = e.Code
+				}
+				assert.Equal(t, map[string]string{
+					"album_id":         "mismatch",
+					"title":            "required",
+					"duration_seconds": "required",
+					"isrc":             "invalid_format",
+				}, codes)
+
+				rr = serve(t, r, "PUT", "/albums/1/tracks/1", `{"number": 2, "title": "T", "duration_seconds": 1}`)
+				assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+				// 存在しないアルバム
+				rr = serve(t, r, "GET", "/albums/99/tracks", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+				rr = serve(t, r, "POST", "/albums/99/tracks", `{"title": "T", "duration_seconds": 1}`)
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+			})
+
+			// ?include=tracks でアルバムにトラックを含める
+			t.Run("Include", func(t *testing.T) {
+				r := newRouter(t)
+				for _, body := range []string{
+					`{"title": "A", "duration_seconds": 60}`,
+					`{"title": "B", "duration_seconds": 70}`,
+				} {
+					serve(t, r, "POST", "/albums/1/tracks", body)
+				}
+
+				rr := serve(t, r, "GET", "/albums/1", "")
+				assert.NotContains(t, rr.Body.String(), `"tracks"`)
+
+				rr = serve(t, r, "GET", "/albums/1?include=tracks", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.JSONEq(t, `{
+					"id": 1, "title": "Alice's 1st Album", "singer": {"id": 1, "name": "Alice"}, "singers": [{"id": 1, "name": "Alice", "role": "primary"}],
+					"tracks": [
+						{"album_id": 1, "number": 1, "title": "A", "duration_seconds": 60},
+						{"album_id": 1, "number": 2, "title": "B", "duration_seconds": 70}
+					]
+				}`, withoutTimestamps(t, rr.Body.Bytes()))
+
+				// トラックを変更するとタグが変わる
+				tag := rr.Header().Get("ETag")
+				serve(t, r, "PUT", "/albums/1/tracks/2", `{"title": "B2", "duration_seconds": 70}`)
+				rr = serveConditional(t, r, "GET", "/albums/1?include=tracks", "If-None-Match", tag, "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.NotEqual(t, tag, rr.Header().Get("ETag"))
+
+				rr = serve(t, r, "GET", "/albums?include=tracks&singer_id=1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+				var albums []struct {
+					ID     int               `json:"id"`
+					Tracks []json.RawMessage `json:"tracks"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &albums); err != nil {
+					t.Fatal(err)
+				}
+				if assert.Len(t, albums, 2) {
+					assert.Len(t, albums[0].Tracks, 2)
+					assert.Len(t, albums[1].Tracks, 0)
+				}
+
+				rr = serve(t, r, "GET", "/albums?include=reviews", "")
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, "include invalid_value", queryParamError(t, rr.Body.Bytes()))
+				rr = serve(t, r, "GET", "/albums/1?include=tracks,reviews", "")
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Equal(t, "include invalid_value", queryParamError(t, rr.Body.Bytes()))
+			})
+
+			// アルバムを論理削除するとトラックも見えなくなり、復元すると戻る
+			t.Run("AlbumDeleted", func(t *testing.T) {
+				r := newRouter(t)
+				serve(t, r, "POST", "/albums/2/tracks", `{"title": "A", "duration_seconds": 60}`)
+
+				rr := serve(t, r, "DELETE", "/albums/2", "")
+				assert.Equal(t, http.StatusNoContent, rr.Code)
+				rr = serve(t, r, "GET", "/albums/2/tracks/1", "")
+				assert.Equal(t, http.StatusNotFound, rr.Code)
+
+				serve(t, r, "POST", "/albums/2:restore", "")
+				rr = serve(t, r, "GET", "/albums/2/tracks/1", "")
+				assert.Equal(t, http.StatusOK, rr.Code)
+			})
+		})
+	}
+}
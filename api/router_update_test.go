@@ -0,0 +1,130 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// PUT /singers/{id} と PATCH /singers/{id} のテスト
+func TestSingerUpdate(t *testing.T) {
+	t.Run("Put", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PUT", "/singers/1", `{"name": "Alicia"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = serve(t, r, "GET", "/singers/1", "")
+		var singer model.Singer
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &singer); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, model.Singer{ID: 1, Name: "Alicia"}, singer)
+	})
+
+	// 存在しない歌手は404
+	t.Run("PutNotExist", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PUT", "/singers/999", `{"name": "Nobody"}`)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	// ボディのIDがパスと異なる場合は422
+	t.Run("PutIDMismatch", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PUT", "/singers/1", `{"id": 2, "name": "Alicia"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PATCH", "/singers/2", `{"name": "Bella B."}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var singer model.Singer
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &singer); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, model.Singer{ID: 2, Name: "Bella B."}, singer)
+	})
+
+	// パッチがオブジェクトでない場合は400
+	t.Run("PatchNotObject", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PATCH", "/singers/2", `["name"]`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// PUT /albums/{id} と PATCH /albums/{id} のテスト
+func TestAlbumUpdate(t *testing.T) {
+	t.Run("Put", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PUT", "/albums/1", `{"title": "Alice's Best", "singer_id": 2}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var album model.Album
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, model.Album{ID: 1, Title: "Alice's Best", SingerID: 2, Artists: soloArtists(2)}, album)
+	})
+
+	// バリデーションエラー
+	t.Run("PutInvalid", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PUT", "/albums/1", `{"singer_id": 2}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	// 存在しない歌手への付け替えは422
+	t.Run("PutUnknownSinger", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PUT", "/albums/1", `{"title": "Alice's Best", "singer_id": 999}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	// 指定した項目だけが変更される
+	t.Run("Patch", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PATCH", "/albums/3", `{"title": "Bella's Debut"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var album model.Album
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, model.Album{ID: 3, Title: "Bella's Debut", SingerID: 2, Artists: soloArtists(2)}, album)
+	})
+
+	// null を指定した項目は削除され、バリデーションエラーになる
+	t.Run("PatchRemoveRequired", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PATCH", "/albums/3", `{"title": null}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+		// 変更されていないことを確認
+		rr = serve(t, r, "GET", "/albums/3", "")
+		var album model.AlbumSinger
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Bella's 1st Album", album.Title)
+	})
+
+	// IDは変更できない
+	t.Run("PatchID", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PATCH", "/albums/3", `{"id": 5}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	})
+
+	t.Run("PatchNotExist", func(t *testing.T) {
+		r := api.NewRouter()
+		rr := serve(t, r, "PATCH", "/albums/999", `{"title": "x"}`)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
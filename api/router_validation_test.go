@@ -0,0 +1,132 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// 歌手・アルバムのバリデーション
+// 違反はまとめて problem+json の errors に {field, code, message} の一覧で返す
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		status   int
+		response string // 成功した場合のレスポンス
+		errors   string // 失敗した場合の problem+json の errors
+	}{
+		{
+			name: "SingerEmptyName", method: "POST", path: "/singers",
+			body: `{"name": "   "}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "name", "code": "required", "message": "is required"}
+			]`,
+		},
+		{
+			name: "SingerAllViolations", method: "POST", path: "/singers",
+			body: `{"id": -1}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "id", "code": "out_of_range", "message": "must be a positive integer"},
+				{"field": "name", "code": "required", "message": "is required"}
+			]`,
+		},
+		{
+			name: "SingerNameTooLong", method: "POST", path: "/singers",
+			body: `{"name": "` + strings.Repeat("あ", 101) + `"}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "name", "code": "too_long", "message": "must be at most 100 characters"}
+			]`,
+		},
+		{
+			name: "SingerNameMaxLength", method: "POST", path: "/singers",
+			body: `{"id": 6, "name": "` + strings.Repeat("あ", 100) + `"}`, status: http.StatusCreated,
+			response: `{"id": 6, "name": "` + strings.Repeat("あ", 100) + `"}`,
+		},
+		{
+			name: "SingerControlChars", method: "POST", path: "/singers",
+			body: `{"name": "Al\u0007ice"}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "name", "code": "invalid_chars", "message": "must not contain control characters"}
+			]`,
+		},
+		{
+			// 前後の空白を取り除き、結合文字を NFC に揃える (e + U+0301 → é)
+			name: "SingerNormalize", method: "POST", path: "/singers",
+			body: `{"id": 6, "name": "  Beyonce\u0301 \t"}`, status: http.StatusCreated,
+			response: `{"id": 6, "name": "Beyonc\u00e9"}`,
+		},
+		{
+			name: "SingerPutMismatch", method: "PUT", path: "/singers/1",
+			body: `{"id": 2, "name": ""}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "id", "code": "mismatch", "message": "must match the id in the path"},
+				{"field": "name", "code": "required", "message": "is required"}
+			]`,
+		},
+		{
+			name: "SingerPatchRemoveName", method: "PATCH", path: "/singers/1",
+			body: `{"name": null}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "name", "code": "required", "message": "is required"}
+			]`,
+		},
+		{
+			name: "AlbumAllViolations", method: "POST", path: "/albums",
+			body: `{}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "title", "code": "required", "message": "is required"},
+				{"field": "singer_id", "code": "required", "message": "is required"}
+			]`,
+		},
+		{
+			name: "AlbumPatchIDAndTitle", method: "PATCH", path: "/albums/1",
+			body: `{"id": 5, "title": " "}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "id", "code": "immutable", "message": "cannot be changed"},
+				{"field": "title", "code": "required", "message": "is required"}
+			]`,
+		},
+		{
+			name: "AlbumPatchType", method: "PATCH", path: "/albums/1",
+			body: `{"singer_id": "2"}`, status: http.StatusUnprocessableEntity,
+			errors: `[
+				{"field": "singer_id", "code": "invalid_type", "message": "must be an integer, got string"}
+			]`,
+		},
+		{
+			name: "AlbumTitleTrimmed", method: "PUT", path: "/albums/1",
+			body: `{"title": " New Title ", "singer_id": 1}`, status: http.StatusOK,
+			response: `{"id": 1, "title": "New Title", "singer_id": 1, "artists": [{"singer_id": 1, "role": "primary"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(t, api.NewRouter(), tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.status, rr.Code, rr.Body.String())
+			if tt.errors == "" {
+				assert.JSONEq(t, tt.response, withoutTimestamps(t, rr.Body.Bytes()))
+				return
+			}
+
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			var problem struct {
+				Type   string          `json:"type"`
+				Status int             `json:"status"`
+				Errors json.RawMessage `json:"errors"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "/problems/validation-failed", problem.Type)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.JSONEq(t, tt.errors, string(problem.Errors))
+		})
+	}
+}
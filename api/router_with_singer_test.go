@@ -10,6 +10,7 @@ import (
 
 	"github.com/pulse227/server-recruit-challenge-sample/api"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,14 +37,14 @@ func TestAlbumSingerGetAll(t *testing.T) {
 		log.Print("req: " + rr.Body.String())
 
 		expected := []*model.AlbumSinger{
-			{ID: 1, Title: "Alice's 1st Album", Singer: model.Singer{ID: 1, Name: "Alice"}},
-			{ID: 2, Title: "Alice's 2nd Album", Singer: model.Singer{ID: 1, Name: "Alice"}},
-			{ID: 3, Title: "Bella's 1st Album", Singer: model.Singer{ID: 2, Name: "Bella"}},
+			{ID: 1, Title: "Alice's 1st Album", Singer: &model.Singer{ID: 1, Name: "Alice"}, Singers: soloCredit(1, "Alice")},
+			{ID: 2, Title: "Alice's 2nd Album", Singer: &model.Singer{ID: 1, Name: "Alice"}, Singers: soloCredit(1, "Alice")},
+			{ID: 3, Title: "Bella's 1st Album", Singer: &model.Singer{ID: 2, Name: "Bella"}, Singers: soloCredit(2, "Bella")},
 		}
 
 		var albums []*model.AlbumSinger
 		// レスポンスのボディを確認
-		if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &albums); err != nil {
 			t.Fatal(err)
 		}
 
@@ -73,11 +74,11 @@ func TestAlbumSingerGet(t *testing.T) {
 
 		log.Print("req: " + rr.Body.String())
 
-		expected := model.AlbumSinger{ID: 1, Title: "Alice's 1st Album", Singer: model.Singer{ID: 1, Name: "Alice"}}
+		expected := model.AlbumSinger{ID: 1, Title: "Alice's 1st Album", Singer: &model.Singer{ID: 1, Name: "Alice"}, Singers: soloCredit(1, "Alice")}
 
 		var album model.AlbumSinger
 		// レスポンスのボディを確認
-		if err := json.NewDecoder(rr.Body).Decode(&album); err != nil {
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
 			t.Fatal(err)
 		}
 
@@ -108,21 +109,23 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
-		// レスポンスのステータスコード(200)を確認
-		if status := rr.Code; status != http.StatusOK {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		// レスポンスのステータスコード(201)を確認
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
 		}
+		assert.Equal(t, "/albums/4", rr.Header().Get("Location"))
 
-		expected := &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1}
+		expected := &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1, Artists: soloArtists(1)}
 
 		var albums *model.Album
 		// レスポンスのボディを確認
-		if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &albums); err != nil {
 			t.Fatal(err)
 		}
 		// レスポンスのボディを確認
@@ -133,7 +136,7 @@ func TestAlbumSingerPost(t *testing.T) {
 	})
 
 	// IDが重複した場合はそのまま上書きをする //
-	t.Run("Overwrite", func(t *testing.T) {
+	t.Run("DuplicateID", func(t *testing.T) {
 		// ルーターを作成
 		r := api.NewRouter()
 		// リクエストを作成
@@ -149,29 +152,32 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
-		// レスポンスのステータスコード(200)を確認
-		if status := rr.Code; status != http.StatusOK {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		// レスポンスのステータスコード(409)を確認
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
 		}
 
-		expected := &model.Album{ID: 1, Title: "Alice's 3rd Album", SingerID: 1}
+		log.Print("TEST2 重複POST: " + rr.Body.String())
 
-		var albums *model.Album
-		// レスポンスのボディを確認
-		if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
+		// 既存のアルバムが上書きされていないことを確認
+		req, err = http.NewRequest("GET", "/albums/1", nil)
+		if err != nil {
 			t.Fatal(err)
 		}
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
 
-		// レスポンスのボディを確認
-		assert.Equal(t, expected, albums)
-
-		// レスポンスのボディを確認
-		log.Print("TEST2 上書きPOST: " + rr.Body.String())
+		var album model.AlbumSinger
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Alice's 1st Album", album.Title)
 	})
 
 	// IDが文字列の場合はエラーを返す //
@@ -191,6 +197,7 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
@@ -224,19 +231,28 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
 		}
 
 		log.Print("TEST4 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 201)
+
+		// サーバーで払い出したIDが返ってくることを確認
+		var album *model.Album
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1, Artists: soloArtists(1)}, album)
+		assert.Equal(t, "/albums/4", rr.Header().Get("Location"))
 	})
 
 	// titleが空の場合はエラーを返す
@@ -256,19 +272,20 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
 		}
 
 		log.Print("TEST5 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 422)
 
 	})
 
@@ -289,6 +306,7 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
@@ -322,19 +340,28 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
 		}
 
 		log.Print("TEST7 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 201)
+
+		// サーバーで払い出したIDが返ってくることを確認
+		var album *model.Album
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1, Artists: soloArtists(1)}, album)
+		assert.Equal(t, "/albums/4", rr.Header().Get("Location"))
 
 	})
 
@@ -354,19 +381,20 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
 		}
 
 		log.Print("TEST8 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 422)
 
 	})
 
@@ -387,19 +415,20 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
 		}
 
 		log.Print("TEST9 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 422)
 
 	})
 
@@ -420,19 +449,28 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
 		}
 
 		log.Print("TEST10 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 201)
+
+		// サーバーで払い出したIDが返ってくることを確認
+		var album *model.Album
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &album); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &model.Album{ID: 4, Title: "Alice's 3rd Album", SingerID: 1, Artists: soloArtists(1)}, album)
+		assert.Equal(t, "/albums/4", rr.Header().Get("Location"))
 
 	})
 
@@ -453,19 +491,20 @@ func TestAlbumSingerPost(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusBadRequest {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
 		}
 
 		log.Print("TEST11 REQUIRE Code: ", rr.Code, " ", rr.Body.String())
 
-		assert.Equal(t, rr.Code, 400)
+		assert.Equal(t, rr.Code, 422)
 
 	})
 
@@ -502,7 +541,7 @@ func TestAlbumSingerDelete(t *testing.T) {
 
 		assert.Equal(t, rr.Code, 204)
 
-		// 削除したアルバムを取得し、500が返ってくることを確認
+		// 削除したアルバムを取得し、404が返ってくることを確認
 		req, err = http.NewRequest("GET", "/albums/1", nil)
 		// 作成に失敗した場合
 		if err != nil {
@@ -514,13 +553,13 @@ func TestAlbumSingerDelete(t *testing.T) {
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコードを確認
-		if status := rr.Code; status != http.StatusInternalServerError {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 		}
 
 		log.Print("TEST1 削除後の取得: " + rr.Body.String())
 
-		assert.Equal(t, rr.Code, 500)
+		assert.Equal(t, rr.Code, 404)
 
 		// t.Fatal()
 	})
@@ -541,14 +580,14 @@ func TestAlbumSingerDelete(t *testing.T) {
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
-		// レスポンスのステータスコード (500) を確認
-		if status := rr.Code; status != http.StatusInternalServerError {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+		// レスポンスのステータスコード (404) を確認
+		if status := rr.Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 		}
 
 		log.Print("TEST2 削除失敗: " + rr.Body.String())
 
-		assert.Equal(t, rr.Code, 500)
+		assert.Equal(t, rr.Code, 404)
 
 		// t.Fatal()
 
@@ -558,7 +597,7 @@ func TestAlbumSingerDelete(t *testing.T) {
 
 // 特殊テスト
 func TestSpecialCase(t *testing.T) {
-	// 存在しないsingerIDが一つ含まれていた場合エラーが起きることを確認
+	// 存在しないsingerIDを参照するアルバムは登録できず、一覧も壊れないことを確認
 	t.Run("SpecialCase", func(t *testing.T) {
 		// ルーターを作成
 		r := api.NewRouter()
@@ -576,28 +615,19 @@ func TestSpecialCase(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
+		req.Header.Set("Content-Type", "application/json")
 		// レスポンスを用意
 		rr := httptest.NewRecorder()
 		// ルーターにリクエストを送信
 		r.ServeHTTP(rr, req)
 
-		// レスポンスのステータスコード(200)を確認
-		if status := rr.Code; status != http.StatusOK {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
-		}
-
-		expected := &model.Album{ID: 10, Title: "Alice's 3rd Album", SingerID: 999}
-
-		var albums *model.Album
-		// レスポンスのボディを確認
-		if err := json.NewDecoder(rr.Body).Decode(&albums); err != nil {
-			t.Fatal(err)
+		// レスポンスのステータスコード(422)を確認
+		if status := rr.Code; status != http.StatusUnprocessableEntity {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusUnprocessableEntity)
 		}
-		// レスポンスのボディを確認
-		assert.Equal(t, expected, albums)
 
 		// レスポンスのボディを確認
-		log.Print("TEST1 正常POST: " + rr.Body.String())
+		log.Print("TEST1 存在しない歌手のPOST: " + rr.Body.String())
 
 		// すべてのアルバムデータを取得する
 		req, err = http.NewRequest("GET", "/albums", nil)
@@ -611,9 +641,82 @@ func TestSpecialCase(t *testing.T) {
 		r.ServeHTTP(rr, req)
 
 		// レスポンスのステータスコード(200)を確認
-		if status := rr.Code; status != http.StatusInternalServerError {
-			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 		}
 
 	})
 }
+
+// アルバムから参照されている歌手の削除
+func TestSingerDeletePolicy(t *testing.T) {
+	// 歌手を削除してアルバム一覧を取得する
+	deleteAndList := func(t *testing.T, r http.Handler, singerID string) (int, []*model.AlbumSinger) {
+		req, err := http.NewRequest("DELETE", "/singers/"+singerID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		code := rr.Code
+
+		req, err = http.NewRequest("GET", "/albums", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET /albums returned %d", rr.Code)
+		}
+		var albums []*model.AlbumSinger
+		if err := json.Unmarshal([]byte(withoutTimestamps(t, rr.Body.Bytes())), &albums); err != nil {
+			t.Fatal(err)
+		}
+		return code, albums
+	}
+
+	// 既定 (restrict) では削除できない
+	t.Run("Restrict", func(t *testing.T) {
+		r := api.NewRouter()
+		code, albums := deleteAndList(t, r, "1")
+		assert.Equal(t, http.StatusConflict, code)
+		assert.Len(t, albums, 3)
+	})
+
+	// アルバムを持たない歌手は削除できる
+	t.Run("RestrictUnreferenced", func(t *testing.T) {
+		r := api.NewRouter()
+		code, _ := deleteAndList(t, r, "3")
+		assert.Equal(t, http.StatusNoContent, code)
+	})
+
+	// cascade ではアルバムも削除される
+	t.Run("Cascade", func(t *testing.T) {
+		r := api.NewRouter(api.WithSingerDeletePolicy(service.SingerDeleteCascade))
+		code, albums := deleteAndList(t, r, "1")
+		assert.Equal(t, http.StatusNoContent, code)
+		assert.Equal(t, []*model.AlbumSinger{
+			{ID: 3, Title: "Bella's 1st Album", Singer: &model.Singer{ID: 2, Name: "Bella"}, Singers: soloCredit(2, "Bella")},
+		}, albums)
+	})
+
+	// orphan ではアルバムは残り、歌手が null になる
+	t.Run("Orphan", func(t *testing.T) {
+		r := api.NewRouter(api.WithSingerDeletePolicy(service.SingerDeleteOrphan))
+		code, albums := deleteAndList(t, r, "1")
+		assert.Equal(t, http.StatusNoContent, code)
+		assert.ElementsMatch(t, []*model.AlbumSinger{
+			{ID: 1, Title: "Alice's 1st Album", Singer: nil, Singers: []*model.CreditedSinger{}},
+			{ID: 2, Title: "Alice's 2nd Album", Singer: nil, Singers: []*model.CreditedSinger{}},
+			{ID: 3, Title: "Bella's 1st Album", Singer: &model.Singer{ID: 2, Name: "Bella"}, Singers: soloCredit(2, "Bella")},
+		}, albums)
+	})
+
+	// 存在しない歌手は404
+	t.Run("NotExist", func(t *testing.T) {
+		r := api.NewRouter()
+		code, _ := deleteAndList(t, r, "999")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
@@ -0,0 +1,18 @@
+package apperr
+
+import "errors"
+
+// ドメイン共通のエラー定義
+// リポジトリ・サービスはこれらを fmt.Errorf の %w でラップして返し、
+// コントローラは errors.Is で判定してHTTPステータスに変換する
+
+var (
+	// 指定したリソースが存在しない
+	ErrNotFound = errors.New("not found")
+	// 既存のリソースと競合する
+	ErrConflict = errors.New("conflict")
+	// 入力値が不正
+	ErrValidation = errors.New("validation failed")
+	// 指定したバージョンが現在のバージョンと一致しない (楽観的排他制御)
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
@@ -6,17 +6,35 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/pulse227/server-recruit-challenge-sample/api"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 	"github.com/stretchr/testify/assert"
 )
 
+// テストで使う現在日時
+// 初期データの作成・更新日時はこの日時になる
+var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func clock() time.Time { return now }
+
+// 初期データの歌手
+var (
+	alice = &model.Singer{ID: 1, Name: "Alice", CreatedAt: now, UpdatedAt: now}
+	bella = &model.Singer{ID: 2, Name: "Bella", CreatedAt: now, UpdatedAt: now}
+)
+
+// メインの歌手だけが参加しているアルバムの参加歌手
+func primary(singer *model.Singer) []*model.CreditedSinger {
+	return []*model.CreditedSinger{{Singer: singer, Role: model.ArtistRolePrimary}}
+}
+
 // 4-1 指定したIDのアルバムを取得するAPI
 func TestAlbumSingerGet(t *testing.T) {
 	t.Run("Get", func(t *testing.T) {
 		// ルーターを作成
-		r := api.NewRouter()
+		r := api.NewRouter(api.WithClock(clock))
 		// HTTPリクエストを作成
 		req, err := http.NewRequest("GET", "/albums/1", nil)
 		// 作成に失敗した場合
@@ -35,7 +53,7 @@ func TestAlbumSingerGet(t *testing.T) {
 
 		log.Print("req: " + rr.Body.String())
 
-		expected := model.AlbumSinger{ID: 1, Title: "Alice's 1st Album", Singer: model.Singer{ID: 1, Name: "Alice"}}
+		expected := model.AlbumSinger{ID: 1, Title: "Alice's 1st Album", CreatedAt: now, UpdatedAt: now, Singer: alice, Singers: primary(alice)}
 
 		var album model.AlbumSinger
 		// レスポンスのボディを確認
@@ -51,7 +69,7 @@ func TestAlbumSingerGet(t *testing.T) {
 func TestAlbumSingerGetAll(t *testing.T) {
 	t.Run("GetAll", func(t *testing.T) {
 		// ルーターを作成
-		r := api.NewRouter()
+		r := api.NewRouter(api.WithClock(clock))
 		// HTTPリクエストを作成
 		req, err := http.NewRequest("GET", "/albums", nil)
 		// 作成に失敗した場合
@@ -71,9 +89,9 @@ func TestAlbumSingerGetAll(t *testing.T) {
 		log.Print("req: " + rr.Body.String())
 
 		expected := []*model.AlbumSinger{
-			{ID: 1, Title: "Alice's 1st Album", Singer: model.Singer{ID: 1, Name: "Alice"}},
-			{ID: 2, Title: "Alice's 2nd Album", Singer: model.Singer{ID: 1, Name: "Alice"}},
-			{ID: 3, Title: "Bella's 1st Album", Singer: model.Singer{ID: 2, Name: "Bella"}},
+			{ID: 1, Title: "Alice's 1st Album", CreatedAt: now, UpdatedAt: now, Singer: alice, Singers: primary(alice)},
+			{ID: 2, Title: "Alice's 2nd Album", CreatedAt: now, UpdatedAt: now, Singer: alice, Singers: primary(alice)},
+			{ID: 3, Title: "Bella's 1st Album", CreatedAt: now, UpdatedAt: now, Singer: bella, Singers: primary(bella)},
 		}
 
 		var albums []*model.AlbumSinger
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
+	"gopkg.in/yaml.v3"
+)
+
+// ストレージの種類
+const (
+	BackendMemory = "memory"
+	BackendSQLite = "sqlite"
+)
+
+// サーバーの設定
+// 優先順位は 既定値 < 設定ファイル < 環境変数 < コマンドラインフラグ
+type Config struct {
+	Addr            string        `yaml:"addr"`             // 待ち受けアドレス
+	ReadTimeout     time.Duration `yaml:"read_timeout"`     // リクエストの読み込みのタイムアウト (0 の場合は無制限)
+	WriteTimeout    time.Duration `yaml:"write_timeout"`    // レスポンスの書き込みのタイムアウト (0 の場合は無制限)
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // Graceful Shutdown の待ち時間
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay"`   // /readyz を失敗させてから Shutdown を始めるまでの待ち時間
+	Storage         Storage       `yaml:"storage"`
+	LogLevel        string        `yaml:"log_level"`      // debug, info, warn, error
+	LogFormat       string        `yaml:"log_format"`     // text, json
+	SeedFile        string        `yaml:"seed_file"`      // 起動時に投入する初期データ (YAML / JSON)
+	MaxBodyBytes    int64         `yaml:"max_body_bytes"` // リクエストボディの大きさの上限
+
+	// アルバムから参照されている歌手を削除するときの方針 (restrict, cascade, orphan)
+	SingerDeletePolicy string `yaml:"singer_delete_policy"`
+
+	Purge Purge `yaml:"purge"`
+	Admin Admin `yaml:"admin"`
+}
+
+// 管理用の API (/admin/) と include_deleted の設定
+type Admin struct {
+	// リクエストの Authorization: Bearer で渡すトークン (空の場合は管理用の機能を無効にする)
+	// コマンドラインはほかのユーザーから見えることがあるため、設定ファイルか環境変数で指定する
+	Token        string        `yaml:"token"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes"` // 一括取り込みのリクエストボディの大きさの上限
+	Timeout      time.Duration `yaml:"timeout"`        // 一括取り込み・書き出しの読み書きのタイムアウト (0 の場合は無制限)
+}
+
+// 論理削除したデータの完全削除の設定
+type Purge struct {
+	Interval  time.Duration `yaml:"interval"`  // 完全削除を実行する間隔 (0 の場合は実行しない)
+	Retention time.Duration `yaml:"retention"` // 論理削除してから完全削除するまでの保存期間
+}
+
+// 永続化の設定
+type Storage struct {
+	Backend string `yaml:"backend"` // memory または sqlite
+	DSN     string `yaml:"dsn"`     // sqlite の場合の接続先 (例: file:app.db)
+}
+
+// 既定値
+func Default() *Config {
+	return &Config{
+		Addr:               ":8888",
+		ReadTimeout:        10 * time.Second,
+		WriteTimeout:       10 * time.Second,
+		ShutdownTimeout:    5 * time.Second,
+		Storage:            Storage{Backend: BackendMemory},
+		LogLevel:           "info",
+		LogFormat:          logging.FormatText,
+		MaxBodyBytes:       1 << 20,
+		SingerDeletePolicy: "restrict",
+		Purge:              Purge{Interval: time.Hour, Retention: 30 * 24 * time.Hour},
+		Admin:              Admin{MaxBodyBytes: 64 << 20, Timeout: 10 * time.Minute},
+	}
+}
+
+// 環境変数名
+const (
+	envConfig             = "APP_CONFIG"
+	envAddr               = "APP_ADDR"
+	envReadTimeout        = "APP_READ_TIMEOUT"
+	envWriteTimeout       = "APP_WRITE_TIMEOUT"
+	envShutdownTimeout    = "APP_SHUTDOWN_TIMEOUT"
+	envShutdownDelay      = "APP_SHUTDOWN_DELAY"
+	envStorageBackend     = "APP_STORAGE_BACKEND"
+	envStorageDSN         = "APP_STORAGE_DSN"
+	envLogLevel           = "APP_LOG_LEVEL"
+	envLogFormat          = "APP_LOG_FORMAT"
+	envSeedFile           = "APP_SEED_FILE"
+	envMaxBodyBytes       = "APP_MAX_BODY_BYTES"
+	envSingerDeletePolicy = "APP_SINGER_DELETE_POLICY"
+	envPurgeInterval      = "APP_PURGE_INTERVAL"
+	envPurgeRetention     = "APP_PURGE_RETENTION"
+	envAdminToken         = "APP_ADMIN_TOKEN"
+	envAdminMaxBodyBytes  = "APP_ADMIN_MAX_BODY_BYTES"
+	envAdminTimeout       = "APP_ADMIN_TIMEOUT"
+
+	// 以前から使っている環境変数 (指定された場合は sqlite を使用する)
+	envLegacyDSN = "DB_DSN"
+)
+
+// コマンドライン引数、環境変数、設定ファイルから設定を読み込み、検証する
+// args には os.Args[1:]、getenv には os.Getenv を渡す
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	// フラグは最後に適用するが、設定ファイルのパスを知るために先に解析する
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	var (
+		path     = fs.String("config", "", "設定ファイル (YAML / JSON) のパス ["+envConfig+"]")
+		addr     = fs.String("addr", "", "待ち受けアドレス ["+envAddr+"]")
+		read     = fs.Duration("read-timeout", 0, "リクエストの読み込みのタイムアウト ["+envReadTimeout+"]")
+		write    = fs.Duration("write-timeout", 0, "レスポンスの書き込みのタイムアウト ["+envWriteTimeout+"]")
+		shutdown = fs.Duration("shutdown-timeout", 0, "Graceful Shutdown の待ち時間 ["+envShutdownTimeout+"]")
+		delay    = fs.Duration("shutdown-delay", 0, "/readyz を失敗させてから Shutdown を始めるまでの待ち時間 ["+envShutdownDelay+"]")
+		backend  = fs.String("storage", "", "ストレージ (memory, sqlite) ["+envStorageBackend+"]")
+		dsn      = fs.String("dsn", "", "sqlite の接続先 ["+envStorageDSN+"]")
+		level    = fs.String("log-level", "", "ログレベル (debug, info, warn, error) ["+envLogLevel+"]")
+		format   = fs.String("log-format", "", "ログの出力形式 (text, json) ["+envLogFormat+"]")
+		seedFile = fs.String("seed", "", "初期データのファイル ["+envSeedFile+"]")
+		maxBody  = fs.Int64("max-body-bytes", 0, "リクエストボディの大きさの上限 (バイト) ["+envMaxBodyBytes+"]")
+		policy   = fs.String("singer-delete-policy", "", "歌手の削除方針 (restrict, cascade, orphan) ["+envSingerDeletePolicy+"]")
+		purgeInt = fs.Duration("purge-interval", 0, "論理削除したデータを完全削除する間隔 (0 で無効) ["+envPurgeInterval+"]")
+		purgeRet = fs.Duration("purge-retention", 0, "論理削除したデータの保存期間 ["+envPurgeRetention+"]")
+		adminMax = fs.Int64("admin-max-body-bytes", 0, "一括取り込みのリクエストボディの大きさの上限 (バイト) ["+envAdminMaxBodyBytes+"]")
+		adminTO  = fs.Duration("admin-timeout", 0, "一括取り込み・書き出しの読み書きのタイムアウト (0 で無制限) ["+envAdminTimeout+"]")
+	)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+
+	// 設定ファイル
+	if *path == "" {
+		*path = getenv(envConfig)
+	}
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	// 環境変数
+	if err := cfg.loadEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	// 明示的に指定されたフラグだけを上書きする
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "read-timeout":
+			cfg.ReadTimeout = *read
+		case "write-timeout":
+			cfg.WriteTimeout = *write
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = *shutdown
+		case "shutdown-delay":
+			cfg.ShutdownDelay = *delay
+		case "storage":
+			cfg.Storage.Backend = *backend
+		case "dsn":
+			cfg.Storage.DSN = *dsn
+		case "log-level":
+			cfg.LogLevel = *level
+		case "log-format":
+			cfg.LogFormat = *format
+		case "seed":
+			cfg.SeedFile = *seedFile
+		case "max-body-bytes":
+			cfg.MaxBodyBytes = *maxBody
+		case "singer-delete-policy":
+			cfg.SingerDeletePolicy = *policy
+		case "purge-interval":
+			cfg.Purge.Interval = *purgeInt
+		case "purge-retention":
+			cfg.Purge.Retention = *purgeRet
+		case "admin-max-body-bytes":
+			cfg.Admin.MaxBodyBytes = *adminMax
+		case "admin-timeout":
+			cfg.Admin.Timeout = *adminTO
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 設定ファイルを読み込む
+// JSON は YAML として読めるので、拡張子に関係なく YAML として解析する
+func (c *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	// 綴りの間違いに気づけるように、未知のキーはエラーにする
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// 環境変数を読み込む
+func (c *Config) loadEnv(getenv func(string) string) error {
+	if dsn := getenv(envLegacyDSN); dsn != "" {
+		c.Storage.Backend = BackendSQLite
+		c.Storage.DSN = dsn
+	}
+
+	var errs []error
+	setString := func(name string, dst *string) {
+		if v := getenv(name); v != "" {
+			*dst = v
+		}
+	}
+	setDuration := func(name string, dst *time.Duration) {
+		v := getenv(name)
+		if v == "" {
+			return
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid duration %q (e.g. 5s, 1m)", name, v))
+			return
+		}
+		*dst = d
+	}
+	setString(envAddr, &c.Addr)
+	setDuration(envReadTimeout, &c.ReadTimeout)
+	setDuration(envWriteTimeout, &c.WriteTimeout)
+	setDuration(envShutdownTimeout, &c.ShutdownTimeout)
+	setDuration(envShutdownDelay, &c.ShutdownDelay)
+	setString(envStorageBackend, &c.Storage.Backend)
+	setString(envStorageDSN, &c.Storage.DSN)
+	setString(envLogLevel, &c.LogLevel)
+	setString(envLogFormat, &c.LogFormat)
+	setString(envSeedFile, &c.SeedFile)
+	setInt64 := func(name string, dst *int64) {
+		v := getenv(name)
+		if v == "" {
+			return
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid integer %q", name, v))
+			return
+		}
+		*dst = n
+	}
+	setInt64(envMaxBodyBytes, &c.MaxBodyBytes)
+	setString(envSingerDeletePolicy, &c.SingerDeletePolicy)
+	setDuration(envPurgeInterval, &c.Purge.Interval)
+	setDuration(envPurgeRetention, &c.Purge.Retention)
+	setString(envAdminToken, &c.Admin.Token)
+	setInt64(envAdminMaxBodyBytes, &c.Admin.MaxBodyBytes)
+	setDuration(envAdminTimeout, &c.Admin.Timeout)
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// 設定を検証する
+// 問題がすべて分かるように、最初の1件で止めずにまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr: %q is not a valid listen address (e.g. :8888, 127.0.0.1:8888)", c.Addr))
+	}
+	if c.ReadTimeout < 0 {
+		errs = append(errs, fmt.Errorf("read_timeout: must not be negative, got %s", c.ReadTimeout))
+	}
+	if c.WriteTimeout < 0 {
+		errs = append(errs, fmt.Errorf("write_timeout: must not be negative, got %s", c.WriteTimeout))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout: must be positive, got %s", c.ShutdownTimeout))
+	}
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, fmt.Errorf("shutdown_delay: must not be negative, got %s", c.ShutdownDelay))
+	}
+	switch c.Storage.Backend {
+	case BackendMemory:
+	case BackendSQLite:
+		if c.Storage.DSN == "" {
+			errs = append(errs, errors.New("storage.dsn: required when storage.backend is sqlite"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage.backend: unknown backend %q (memory, sqlite)", c.Storage.Backend))
+	}
+	if _, err := c.Level(); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.LogFormat {
+	case logging.FormatText, logging.FormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("log_format: unknown format %q (text, json)", c.LogFormat))
+	}
+	if c.SeedFile != "" {
+		if _, err := os.Stat(c.SeedFile); err != nil {
+			errs = append(errs, fmt.Errorf("seed_file: %w", err))
+		}
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, fmt.Errorf("max_body_bytes: must be positive, got %d", c.MaxBodyBytes))
+	}
+	if _, err := service.ParseSingerDeletePolicy(c.SingerDeletePolicy); err != nil {
+		errs = append(errs, fmt.Errorf("singer_delete_policy: %w", err))
+	}
+	if c.Purge.Interval < 0 {
+		errs = append(errs, fmt.Errorf("purge.interval: must not be negative, got %s", c.Purge.Interval))
+	}
+	if c.Purge.Retention < 0 {
+		errs = append(errs, fmt.Errorf("purge.retention: must not be negative, got %s", c.Purge.Retention))
+	}
+	if c.Admin.MaxBodyBytes <= 0 {
+		errs = append(errs, fmt.Errorf("admin.max_body_bytes: must be positive, got %d", c.Admin.MaxBodyBytes))
+	}
+	if c.Admin.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("admin.timeout: must not be negative, got %s", c.Admin.Timeout))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ログレベルを slog.Level に変換する
+func (c *Config) Level() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("log_level: unknown level %q (debug, info, warn, error)", c.LogLevel)
+	}
+	return level, nil
+}
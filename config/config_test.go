@@ -0,0 +1,122 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// テスト用の環境変数
+func env(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+// テスト用の設定ファイルを作成する
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		cfg, err := config.Load(nil, env(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, config.Default(), cfg)
+	})
+
+	// 既定値 < 設定ファイル < 環境変数 < フラグ
+	t.Run("Precedence", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+addr: ":9000"
+shutdown_timeout: 30s
+log_level: debug
+storage:
+  backend: sqlite
+  dsn: file:from-file.db
+`)
+		cfg, err := config.Load([]string{"-config", path, "-addr", ":9002"}, env(map[string]string{
+			"APP_ADDR":             ":9001",
+			"APP_SHUTDOWN_TIMEOUT": "10s",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, ":9002", cfg.Addr)
+		assert.Equal(t, 10*time.Second, cfg.ShutdownTimeout)
+		assert.Equal(t, "debug", cfg.LogLevel)
+		assert.Equal(t, config.Storage{Backend: "sqlite", DSN: "file:from-file.db"}, cfg.Storage)
+	})
+
+	t.Run("JSONFile", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"addr": "127.0.0.1:8080", "read_timeout": "3s"}`)
+		cfg, err := config.Load(nil, env(map[string]string{"APP_CONFIG": path}))
+		assert.NoError(t, err)
+		assert.Equal(t, "127.0.0.1:8080", cfg.Addr)
+		assert.Equal(t, 3*time.Second, cfg.ReadTimeout)
+	})
+
+	t.Run("LegacyDSN", func(t *testing.T) {
+		cfg, err := config.Load(nil, env(map[string]string{"DB_DSN": "file:app.db"}))
+		assert.NoError(t, err)
+		assert.Equal(t, config.Storage{Backend: "sqlite", DSN: "file:app.db"}, cfg.Storage)
+	})
+
+	// トークンはコマンドラインでは指定できない
+	t.Run("Admin", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+admin:
+  token: from-file
+  timeout: 1m
+`)
+		cfg, err := config.Load([]string{"-config", path, "-admin-timeout", "0s"}, env(map[string]string{
+			"APP_ADMIN_TOKEN":          "from-env",
+			"APP_ADMIN_MAX_BODY_BYTES": "1024",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, config.Admin{Token: "from-env", MaxBodyBytes: 1024, Timeout: 0}, cfg.Admin)
+
+		_, err = config.Load([]string{"-admin-token", "secret"}, env(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", "adr: \":9000\"\n")
+		_, err := config.Load([]string{"-config", path}, env(nil))
+		assert.ErrorContains(t, err, "field adr not found")
+	})
+
+	t.Run("InvalidDuration", func(t *testing.T) {
+		_, err := config.Load(nil, env(map[string]string{"APP_READ_TIMEOUT": "soon"}))
+		assert.ErrorContains(t, err, `APP_READ_TIMEOUT: invalid duration "soon"`)
+	})
+
+	// 不正な値はまとめて報告する
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := config.Load([]string{
+			"-addr", "8888",
+			"-shutdown-timeout", "0s",
+			"-storage", "sqlite",
+			"-log-level", "verbose",
+			"-log-format", "xml",
+			"-max-body-bytes", "0",
+			"-singer-delete-policy", "ignore",
+			"-admin-max-body-bytes", "-1",
+		}, env(nil))
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `addr: "8888" is not a valid listen address`)
+			assert.Contains(t, err.Error(), "shutdown_timeout: must be positive")
+			assert.Contains(t, err.Error(), "storage.dsn: required when storage.backend is sqlite")
+			assert.Contains(t, err.Error(), `log_level: unknown level "verbose"`)
+			assert.Contains(t, err.Error(), `log_format: unknown format "xml"`)
+			assert.Contains(t, err.Error(), "max_body_bytes: must be positive")
+			assert.Contains(t, err.Error(), `singer_delete_policy: unknown singer delete policy "ignore"`)
+			assert.Contains(t, err.Error(), "admin.max_body_bytes: must be positive")
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
 	"github.com/pulse227/server-recruit-challenge-sample/service"
 )
 
@@ -22,13 +23,27 @@ func NewAlbumController(service service.AlbumService) *albumController {
 
 // GET /albums のハンドラ
 func (c *albumController) GetAlbumListHandler(w http.ResponseWriter, r *http.Request) {
-	albums, err := c.service.GetAlbumListService(r.Context())
+	// ページング・並び替えのパラメータの取得
+	opts, err := parseListOptions(r, repository.AlbumSortKeys)
 	if err != nil {
-		// エラーの場合サーバーエラーを出して終了
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
+		return
+	}
+
+	// 検索条件の取得
+	filter, err := parseAlbumFilter(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	albums, next, err := c.service.GetAlbumListService(r.Context(), filter, opts)
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 	// レスポンスの作成
+	writePaginationHeaders(w, r, opts, next)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	// JSONレスポンス
@@ -41,17 +56,23 @@ func (c *albumController) GetAlbumDetailHandler(w http.ResponseWriter, r *http.R
 	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		// エラー処理
-		err = fmt.Errorf("invalid path param: %w", err)
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, invalidPathParam("id", err))
 		return
 	}
 	// 特定のアルバムの呼び出し
 	album, err := c.service.GetAlbumService(r.Context(), model.AlbumID(albumID))
 	if err != nil {
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
+		return
+	}
+	// クライアントが持っている表現が最新なら本文を返さない
+	tag := albumTag(album.ID, album.Version)
+	if ifNoneMatch(r, tag) {
+		writeNotModified(w, tag)
 		return
 	}
 	// レスポンス作成
+	setETag(w, tag)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(album)
@@ -59,13 +80,11 @@ func (c *albumController) GetAlbumDetailHandler(w http.ResponseWriter, r *http.R
 
 // POST /albums のハンドラ
 func (c *albumController) PostAlbumHandler(w http.ResponseWriter, r *http.Request) {
-	var album *model.Album
+	album := &model.Album{}
 
 	// リクエストのパース
-	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
-		// パース時のエラー処理
-		err = fmt.Errorf("invalid request body: %w", err)
-		errorHandler(w, r, 400, err.Error())
+	if err := decodeJSONBody(r, album); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
@@ -73,19 +92,21 @@ func (c *albumController) PostAlbumHandler(w http.ResponseWriter, r *http.Reques
 	validation := &AlbumsValidation{}
 
 	if err := validation.ValidateAlbum(album); err != nil {
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, err)
 		return
 	}
 
 	// アルバムの作成
 	if err := c.service.PostAlbumService(r.Context(), album); err != nil {
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
 		return
 	}
 
-	// レスポンス作成
+	// レスポンス作成 (201 Created と作成したアルバムのURL)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
+	w.Header().Set("Location", fmt.Sprintf("/albums/%d", album.ID))
+	setETag(w, albumTag(album.ID, album.Version))
+	w.WriteHeader(201)
 	json.NewEncoder(w).Encode(album)
 }
 
@@ -95,14 +116,26 @@ func (c *albumController) DeleteAlbumHandler(w http.ResponseWriter, r *http.Requ
 	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		// エラー処理
-		err = fmt.Errorf("invalid path param: %w", err)
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	// 削除するバージョンの指定 (If-Match)
+	version, err := ifMatchVersion(r, func() (int, string, error) {
+		album, err := c.service.GetAlbumService(r.Context(), model.AlbumID(albumID))
+		if err != nil {
+			return 0, "", err
+		}
+		return album.Version, albumTag(album.ID, album.Version), nil
+	})
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// アルバムの削除
-	if err := c.service.DeleteAlbumService(r.Context(), model.AlbumID(albumID)); err != nil {
-		errorHandler(w, r, 500, err.Error())
+	if err := c.service.DeleteAlbumService(r.Context(), model.AlbumID(albumID), version); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
 	"github.com/pulse227/server-recruit-challenge-sample/service"
 )
 
@@ -22,13 +23,40 @@ func NewAlbumSingerController(service service.AlbumSingerService) *albumSingerCo
 
 // GET /albums のハンドラ
 func (c *albumSingerController) GetAlbumListHandler(w http.ResponseWriter, r *http.Request) {
-	albums, err := c.service.GetAlbumSingerListService(r.Context())
+	// ページング・並び替えのパラメータの取得
+	opts, err := parseListOptions(r, repository.AlbumSortKeys)
 	if err != nil {
-		// エラーの場合サーバーエラーを出して終了
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
 		return
 	}
+
+	// 検索条件の取得
+	filter, err := parseAlbumFilter(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// 結合する関連リソースの取得
+	includeTracks, err := parseIncludeTracks(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	albums, next, err := c.service.GetAlbumSingerListService(r.Context(), filter, opts)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if includeTracks {
+		if err := c.service.AttachTracksService(r.Context(), albums); err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
 	// レスポンスの作成
+	writePaginationHeaders(w, r, opts, next)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	// JSONレスポンス
@@ -41,17 +69,44 @@ func (c *albumSingerController) GetAlbumDetailHandler(w http.ResponseWriter, r *
 	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		// エラー処理
-		err = fmt.Errorf("invalid path param: %w", err)
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+	// 結合する関連リソースの取得
+	includeTracks, err := parseIncludeTracks(r)
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 	// 特定のアルバムの呼び出し
 	album, err := c.service.GetAlbumSingerService(r.Context(), model.AlbumID(albumID))
 	if err != nil {
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
+		return
+	}
+	if includeTracks {
+		if err := c.service.AttachTracksService(r.Context(), []*model.AlbumSinger{album}); err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
+	// 歌手の名前が変わったときも表現が変わるので、参加している歌手のバージョンもタグに含める
+	// トラックを含める場合はトラックの変更でも変わるようにする
+	var related []int
+	for _, singer := range album.Singers {
+		related = append(related, singer.Version)
+	}
+	if includeTracks {
+		related = append(related, tracksVersion(album.Tracks))
+	}
+	tag := withRelated(albumTag(album.ID, album.Version), related...)
+	// クライアントが持っている表現が最新なら本文を返さない
+	if ifNoneMatch(r, tag) {
+		writeNotModified(w, tag)
 		return
 	}
 	// レスポンス作成
+	setETag(w, tag)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(album)
@@ -59,13 +114,11 @@ func (c *albumSingerController) GetAlbumDetailHandler(w http.ResponseWriter, r *
 
 // POST /albums のハンドラ
 func (c *albumSingerController) PostAlbumHandler(w http.ResponseWriter, r *http.Request) {
-	var album *model.Album
+	album := &model.Album{}
 
 	// リクエストのパース
-	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
-		// パース時のエラー処理
-		err = fmt.Errorf("invalid request body: %w", err)
-		errorHandler(w, r, 400, err.Error())
+	if err := decodeJSONBody(r, album); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
@@ -73,17 +126,134 @@ func (c *albumSingerController) PostAlbumHandler(w http.ResponseWriter, r *http.
 	validation := &AlbumsValidation{}
 
 	if err := validation.ValidateAlbum(album); err != nil {
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, err)
 		return
 	}
 
 	// アルバムの作成
 	if err := c.service.PostAlbumSingerService(r.Context(), album); err != nil {
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成 (201 Created と作成したアルバムのURL)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/albums/%d", album.ID))
+	setETag(w, albumTag(album.ID, album.Version))
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(album)
+}
+
+// PUT /albums/{id} のハンドラ
+func (c *albumSingerController) PutAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+	// 更新するバージョンの指定 (If-Match)
+	version, err := c.ifMatchVersion(r, model.AlbumID(albumID))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	album := &model.Album{}
+
+	// リクエストのパース
+	if err := decodeJSONBody(r, album); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// ボディのIDは省略可能だが、指定する場合はパスと一致させる
+	var val validator
+	if album.ID != 0 && album.ID != model.AlbumID(albumID) {
+		val.add("id", codeMismatch, "must match the id in the path")
+	}
+	album.ID = model.AlbumID(albumID)
+	album.Version = version
+
+	// リクエストのバリデーション
+	validation := &AlbumsValidation{}
+	val.merge(validation.ValidateAlbum(album))
+	if err := val.err(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// アルバムの更新
+	if err := c.service.PutAlbumSingerService(r.Context(), album); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// レスポンス作成
+	setETag(w, albumTag(album.ID, album.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(album)
+}
+
+// PATCH /albums/{id} のハンドラ (JSON Merge Patch)
+func (c *albumSingerController) PatchAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	// 更新するバージョンの指定 (If-Match)
+	precondition, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// リクエストのパース
+	patch, err := readMergePatch(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// パッチを適用した結果に対してバリデーションを行う
+	validation := &AlbumsValidation{}
+
+	// アルバムの部分更新
+	album, err := c.service.PatchAlbumSingerService(r.Context(), model.AlbumID(albumID), func(album *model.Album) error {
+		if err := precondition.check(albumTag(album.ID, album.Version)); err != nil {
+			return err
+		}
+		if err := applyMergePatch(album, patch); err != nil {
+			return err
+		}
+		// 歌手が1人だった頃のクライアントのため、singer_id だけを変更した場合はメインの歌手を置き換える
+		_, hasSinger := patch["singer_id"]
+		_, hasArtists := patch["artists"]
+		switch {
+		case hasSinger && !hasArtists:
+			album.Artists = replacePrimaryArtist(album.Artists, album.SingerID)
+		case hasArtists && !hasSinger:
+			album.SingerID = 0
+		}
+		var val validator
+		if album.ID != model.AlbumID(albumID) {
+			val.add("id", codeImmutable, "cannot be changed")
+		}
+		val.merge(validation.ValidateAlbum(album))
+		return val.err()
+	})
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成
+	setETag(w, albumTag(album.ID, album.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(album)
@@ -95,17 +265,151 @@ func (c *albumSingerController) DeleteAlbumHandler(w http.ResponseWriter, r *htt
 	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		// エラー処理
-		err = fmt.Errorf("invalid path param: %w", err)
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	// 削除するバージョンの指定 (If-Match)
+	version, err := c.ifMatchVersion(r, model.AlbumID(albumID))
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// アルバムの削除
-	if err := c.service.DeleteAlbumSingerService(r.Context(), model.AlbumID(albumID)); err != nil {
-		errorHandler(w, r, 500, err.Error())
+	if err := c.service.DeleteAlbumSingerService(r.Context(), model.AlbumID(albumID), version); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// レスポンス作成
 	w.WriteHeader(204)
 }
+
+// POST /albums/{id}:restore のハンドラ
+// 論理削除したアルバムを元に戻す
+func (c *albumSingerController) RestoreAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	// アルバムの復元
+	album, err := c.service.RestoreAlbumSingerService(r.Context(), model.AlbumID(albumID))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成
+	setETag(w, albumTag(album.ID, album.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(album)
+}
+
+// GET /singers/{id}/albums のハンドラ
+func (c *albumSingerController) GetSingerAlbumListHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	// ページング・並び替えのパラメータの取得
+	opts, err := parseListOptions(r, repository.AlbumSortKeys)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	albums, next, err := c.service.GetSingerAlbumListService(r.Context(), model.SingerID(singerID), opts)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// レスポンスの作成
+	writePaginationHeaders(w, r, opts, next)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	// JSONレスポンス
+	json.NewEncoder(w).Encode(albums)
+}
+
+// POST /singers/{id}/albums のハンドラ
+// singer_id はパスから設定する
+func (c *albumSingerController) PostSingerAlbumHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	album := &model.Album{}
+
+	// リクエストのパース
+	if err := decodeJSONBody(r, album); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// ボディの singer_id は省略可能だが、指定する場合はパスと一致させる
+	var val validator
+	if album.SingerID != 0 && album.SingerID != model.SingerID(singerID) {
+		val.add("singer_id", codeMismatch, "must match the singer id in the path")
+	}
+	album.SingerID = model.SingerID(singerID)
+
+	// リクエストのバリデーション
+	validation := &AlbumsValidation{}
+	val.merge(validation.ValidateAlbum(album))
+	if err := val.err(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// アルバムの作成
+	if err := c.service.PostSingerAlbumService(r.Context(), model.SingerID(singerID), album); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成 (201 Created と作成したアルバムのURL)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/albums/%d", album.ID))
+	setETag(w, albumTag(album.ID, album.Version))
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(album)
+}
+
+// メインの歌手を singerID の歌手だけに置き換える
+// 他の役割の歌手はそのまま残す
+func replacePrimaryArtist(artists []model.AlbumArtist, singerID model.SingerID) []model.AlbumArtist {
+	replaced := make([]model.AlbumArtist, 0, len(artists)+1)
+	if singerID != 0 {
+		replaced = append(replaced, model.AlbumArtist{SingerID: singerID, Role: model.ArtistRolePrimary})
+	}
+	for _, artist := range artists {
+		if artist.Role != model.ArtistRolePrimary {
+			replaced = append(replaced, artist)
+		}
+	}
+	return replaced
+}
+
+// If-Match を現在のアルバムのタグと比較し、書き込むときに比較するバージョンを返す
+func (c *albumSingerController) ifMatchVersion(r *http.Request, albumID model.AlbumID) (int, error) {
+	return ifMatchVersion(r, func() (int, string, error) {
+		album, err := c.service.GetAlbumSingerService(r.Context(), albumID)
+		if err != nil {
+			return 0, "", err
+		}
+		return album.Version, albumTag(album.ID, album.Version), nil
+	})
+}
@@ -0,0 +1,332 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
+)
+
+// 一括取り込み・書き出しの形式
+const (
+	mediaTypeNDJSON = "application/x-ndjson"
+	mediaTypeCSV    = "text/csv"
+)
+
+// 1件の形式が誤っている (JSON として読めないなど)
+const codeMalformed = "malformed"
+
+type catalogController struct {
+	service service.CatalogService
+	// 読み書きのタイムアウト (0 の場合は無制限)
+	// 大きなデータを扱うため、サーバーの ReadTimeout, WriteTimeout の代わりに使う
+	timeout time.Duration
+}
+
+// コンストラクタ
+func NewCatalogController(service service.CatalogService, timeout time.Duration) *catalogController {
+	return &catalogController{service: service, timeout: timeout}
+}
+
+// サーバーの読み書きのタイムアウトを c.timeout に置き換える
+func (c *catalogController) extendDeadline(w http.ResponseWriter, r *http.Request, read bool) {
+	var deadline time.Time
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(deadline)
+	if err == nil && read {
+		err = rc.SetReadDeadline(deadline)
+	}
+	// テストの httptest.ResponseRecorder などは期限に対応していない
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.FromContext(r.Context()).Warn("failed to extend deadline", "error", err)
+	}
+}
+
+// 取り込むデータの1件 (行番号と、model.CatalogRecord の JSON)
+type catalogRow struct {
+	line int
+	data []byte
+}
+
+// POST /admin/import のハンドラ
+// Content-Type が application/x-ndjson の場合は1行に1件の JSON ({"singer": {...}} か {"album": {...}})、
+// text/csv の場合はヘッダー行つきの CSV (catalogCSVColumns の列) を受け付ける
+// すべての行を確認し、問題がある場合は何も登録せずに行ごとのエラーを返す (dry_run=true の場合は確認だけ行う)
+func (c *catalogController) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if err := checkContentType(r, []string{mediaTypeNDJSON, mediaTypeCSV}); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			handleError(w, r, &requestError{status: http.StatusBadRequest, message: "dry_run must be true or false"})
+			return
+		}
+		dryRun = b
+	}
+	c.extendDeadline(w, r, true)
+	body, err := readBody(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// リクエストのパース
+	var rows []catalogRow
+	var val validator
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == mediaTypeCSV {
+		rows, err = parseCatalogCSV(body, &val)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+	} else {
+		rows = parseCatalogNDJSON(body)
+	}
+	if len(rows) == 0 && len(val.errs) == 0 {
+		handleError(w, r, &requestError{status: http.StatusBadRequest, message: "request body must contain at least one record"})
+		return
+	}
+
+	// リクエストのバリデーション (すべての行を確認してからまとめて返す)
+	records := make([]*model.CatalogRecord, 0, len(rows))
+	lines := make([]int, 0, len(rows))
+	for _, row := range rows {
+		if rec := decodeCatalogRow(row, &val); rec != nil {
+			records = append(records, rec)
+			lines = append(lines, row.line)
+		}
+	}
+	if err := val.err(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// 参照先や登録済みのデータとの重複を確認してから登録する
+	result, err := c.service.ImportService(r.Context(), records, dryRun)
+	var importErrs service.ImportErrors
+	if errors.As(err, &importErrs) {
+		for _, ie := range importErrs {
+			val.errs = append(val.errs, FieldError{Line: lines[ie.Index], Field: ie.Field, Code: ie.Code, Message: ie.Message})
+		}
+		err = val.err()
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+// NDJSON を行に分ける (空行は読み飛ばす)
+func parseCatalogNDJSON(body []byte) []catalogRow {
+	var rows []catalogRow
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		rows = append(rows, catalogRow{line: i + 1, data: line})
+	}
+	return rows
+}
+
+// 1行の JSON を読み込み、バリデーションを行う
+// 問題がある場合は val に行番号つきで追加して nil を返す
+func decodeCatalogRow(row catalogRow, val *validator) *model.CatalogRecord {
+	addErr := func(field, code, message string) {
+		val.errs = append(val.errs, FieldError{Line: row.line, Field: field, Code: code, Message: message})
+	}
+
+	rec := &model.CatalogRecord{}
+	dec := json.NewDecoder(bytes.NewReader(row.data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(rec)
+	if err == nil {
+		if _, err := dec.Token(); err != io.EOF {
+			addErr("", codeMalformed, "must contain a single JSON object")
+			return nil
+		}
+	}
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(decodeError(err), &reqErr) && len(reqErr.details) > 0 {
+			for _, fe := range reqErr.details {
+				addErr(fe.Field, fe.Code, fe.Message)
+			}
+		} else {
+			addErr("", codeMalformed, "must be a JSON object")
+		}
+		return nil
+	}
+
+	// 歌手かアルバムのどちらか一方を、IDを指定して取り込む
+	var errs ValidationErrors
+	switch {
+	case (rec.Singer == nil) == (rec.Album == nil):
+		addErr("", codeRequired, "must have exactly one of singer or album")
+		return nil
+	case rec.Singer != nil:
+		if rec.Singer.ID == 0 {
+			addErr("singer.id", codeRequired, "is required")
+		}
+		validation := &SingersValidation{}
+		errors.As(validation.ValidateSinger(rec.Singer), &errs)
+		for _, fe := range errs {
+			addErr("singer."+fe.Field, fe.Code, fe.Message)
+		}
+		if rec.Singer.ID == 0 || len(errs) > 0 {
+			return nil
+		}
+	default:
+		if rec.Album.ID == 0 {
+			addErr("album.id", codeRequired, "is required")
+		}
+		// 削除方針 orphan で歌手を外したアルバムも書き出されるので、そのまま取り込めるようにする
+		validation := &AlbumsValidation{AllowNoPrimary: true}
+		errors.As(validation.ValidateAlbum(rec.Album), &errs)
+		for _, fe := range errs {
+			addErr("album."+fe.Field, fe.Code, fe.Message)
+		}
+		if rec.Album.ID == 0 || len(errs) > 0 {
+			return nil
+		}
+	}
+	return rec
+}
+
+// GET /admin/export のハンドラ
+// 論理削除していない歌手とアルバムをすべて書き出す
+// format: json (既定。{"singers": [...], "albums": [...]} で、初期データのファイルとしても読み込める), ndjson, csv
+func (c *catalogController) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	var cw catalogWriter
+	var contentType string
+	switch format {
+	case "json":
+		cw, contentType = &jsonCatalogWriter{w: w}, "application/json"
+	case "ndjson":
+		cw, contentType = &ndjsonCatalogWriter{enc: json.NewEncoder(w)}, mediaTypeNDJSON
+	case "csv":
+		cw, contentType = newCSVCatalogWriter(w), mediaTypeCSV+"; charset=utf-8"
+	default:
+		handleError(w, r, &requestError{status: http.StatusBadRequest, message: "format must be one of: json, ndjson, csv"})
+		return
+	}
+
+	// 全件を読み込まずに、リポジトリから読んだ順に書き出す
+	c.extendDeadline(w, r, false)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="catalog.%s"`, format))
+	w.WriteHeader(200)
+	err := c.service.ExportService(r.Context(), cw.write)
+	if err == nil {
+		err = cw.close()
+	}
+	if err != nil {
+		// ステータスコードは送信済みなので、接続を切って途中までのデータを完全なものと誤解させない
+		logging.FromContext(r.Context()).Error("export failed", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// カタログの書き出し
+// write には歌手をすべて渡してからアルバムを渡す
+type catalogWriter interface {
+	write(rec *model.CatalogRecord) error
+	close() error
+}
+
+// 1行に1件の JSON で書き出す
+type ndjsonCatalogWriter struct {
+	enc *json.Encoder
+}
+
+func (cw *ndjsonCatalogWriter) write(rec *model.CatalogRecord) error {
+	return cw.enc.Encode(rec)
+}
+
+func (cw *ndjsonCatalogWriter) close() error {
+	return nil
+}
+
+// {"singers": [...], "albums": [...]} の形式で書き出す
+type jsonCatalogWriter struct {
+	w       io.Writer
+	section string // 書き出し中の配列 (書き始める前は空文字列)
+	count   int    // 書き出し中の配列の要素数
+}
+
+func (cw *jsonCatalogWriter) write(rec *model.CatalogRecord) error {
+	var v interface{}
+	if rec.Singer != nil {
+		if err := cw.open("singers"); err != nil {
+			return err
+		}
+		v = rec.Singer
+	} else {
+		if err := cw.open("albums"); err != nil {
+			return err
+		}
+		v = rec.Album
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if cw.count > 0 {
+		b = append([]byte(","), b...)
+	}
+	cw.count++
+	_, err = cw.w.Write(b)
+	return err
+}
+
+// 指定した配列を書き始める (前の配列は閉じる)
+func (cw *jsonCatalogWriter) open(section string) error {
+	for cw.section != section {
+		var s string
+		switch cw.section {
+		case "":
+			s, cw.section = `{"singers":[`, "singers"
+		case "singers":
+			s, cw.section = `],"albums":[`, "albums"
+		default:
+			return errors.New("singers must be written before albums")
+		}
+		if _, err := io.WriteString(cw.w, s); err != nil {
+			return err
+		}
+		cw.count = 0
+	}
+	return nil
+}
+
+func (cw *jsonCatalogWriter) close() error {
+	if err := cw.open("albums"); err != nil {
+		return err
+	}
+	_, err := io.WriteString(cw.w, "]}\n")
+	return err
+}
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// カタログの CSV の列
+// type が singer の行は歌手の列、album の行はアルバムの列を使い、使わない列は空にする
+var catalogCSVColumns = []string{
+	"type", "id",
+	"name", "stage_name", "real_name", "country", "debut_year", "aliases", "external_ids",
+	"title", "singer_id", "artists", "release_date", "genres", "label", "format",
+}
+
+// 値を JSON として読み書きする列 (数値・配列・オブジェクト)
+// 配列とオブジェクトは ["pop","rock"] のように JSON で書く
+var catalogCSVJSONColumns = map[string]bool{
+	"id": true, "debut_year": true, "aliases": true, "external_ids": true,
+	"singer_id": true, "artists": true, "genres": true,
+}
+
+// CSV を行ごとの JSON ({"singer": {...}} か {"album": {...}}) に変換する
+// 行ごとの問題は val に追加し、ヘッダーの誤りなど全体を読めない場合はエラーを返す
+func parseCatalogCSV(body []byte, val *validator) ([]catalogRow, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, message: "malformed CSV header", cause: err}
+	}
+	known := map[string]bool{}
+	for _, col := range catalogCSVColumns {
+		known[col] = true
+	}
+	hasType := false
+	for _, col := range header {
+		if !known[col] {
+			return nil, &requestError{status: http.StatusBadRequest, message: fmt.Sprintf("unknown CSV column %q", col)}
+		}
+		hasType = hasType || col == "type"
+	}
+	if !hasType {
+		return nil, &requestError{status: http.StatusBadRequest, message: `CSV header must include the "type" column`}
+	}
+
+	var rows []catalogRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				val.errs = append(val.errs, FieldError{Line: parseErr.Line, Code: codeMalformed, Message: parseErr.Err.Error()})
+				// 引用符の誤りなどは以降の行の区切りも分からないので読み進めない
+				if !errors.Is(parseErr.Err, csv.ErrFieldCount) {
+					break
+				}
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if row, ok := csvRowJSON(header, record, line, val); ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// CSV の1行を JSON に変換する
+func csvRowJSON(header, record []string, line int, val *validator) (catalogRow, bool) {
+	fields := map[string]json.RawMessage{}
+	key := ""
+	ok := true
+	for i, col := range header {
+		cell := record[i]
+		switch {
+		case col == "type":
+			key = cell
+		case cell == "":
+			// 空の列は省略したものとして扱う
+		case catalogCSVJSONColumns[col]:
+			if !json.Valid([]byte(cell)) {
+				val.errs = append(val.errs, FieldError{Line: line, Field: col, Code: codeInvalidFormat, Message: "must be a JSON value"})
+				ok = false
+				continue
+			}
+			fields[col] = json.RawMessage(cell)
+		default:
+			b, _ := json.Marshal(cell)
+			fields[col] = b
+		}
+	}
+	if key != "singer" && key != "album" {
+		val.errs = append(val.errs, FieldError{Line: line, Field: "type", Code: codeInvalidValue, Message: "must be one of: singer, album"})
+		return catalogRow{}, false
+	}
+	if !ok {
+		return catalogRow{}, false
+	}
+	data, err := json.Marshal(map[string]interface{}{key: fields})
+	if err != nil {
+		return catalogRow{}, false
+	}
+	return catalogRow{line: line, data: data}, true
+}
+
+// CSV で書き出す
+type csvCatalogWriter struct {
+	w       *csv.Writer
+	started bool // ヘッダーを書き出したか
+}
+
+func newCSVCatalogWriter(w io.Writer) *csvCatalogWriter {
+	return &csvCatalogWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvCatalogWriter) write(rec *model.CatalogRecord) error {
+	if !cw.started {
+		if err := cw.w.Write(catalogCSVColumns); err != nil {
+			return err
+		}
+		cw.started = true
+	}
+	cells := map[string]string{}
+	if s := rec.Singer; s != nil {
+		cells["type"] = "singer"
+		cells["id"] = strconv.Itoa(int(s.ID))
+		cells["name"] = s.Name
+		cells["stage_name"] = s.StageName
+		cells["real_name"] = s.RealName
+		cells["country"] = s.Country
+		if s.DebutYear != 0 {
+			cells["debut_year"] = strconv.Itoa(s.DebutYear)
+		}
+		if len(s.Aliases) > 0 {
+			cells["aliases"] = csvJSONCell(s.Aliases)
+		}
+		if len(s.ExternalIDs) > 0 {
+			cells["external_ids"] = csvJSONCell(s.ExternalIDs)
+		}
+	} else {
+		a := rec.Album
+		cells["type"] = "album"
+		cells["id"] = strconv.Itoa(int(a.ID))
+		cells["title"] = a.Title
+		// singer_id は artists から決まるので書き出さない
+		if len(a.Artists) > 0 {
+			cells["artists"] = csvJSONCell(a.Artists)
+		}
+		cells["release_date"] = a.ReleaseDate
+		if len(a.Genres) > 0 {
+			cells["genres"] = csvJSONCell(a.Genres)
+		}
+		cells["label"] = a.Label
+		cells["format"] = string(a.Format)
+	}
+	record := make([]string, len(catalogCSVColumns))
+	for i, col := range catalogCSVColumns {
+		record[i] = cells[col]
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *csvCatalogWriter) close() error {
+	// 1件もない場合もヘッダーは書き出す
+	if !cw.started {
+		if err := cw.w.Write(catalogCSVColumns); err != nil {
+			return err
+		}
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// 配列やオブジェクトの列の値を JSON にする
+func csvJSONCell(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// 文字列や参加歌手のスライス・マップの変換は失敗しない
+		panic(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// JSON のリクエストボディの Content-Type
+const (
+	mediaTypeJSON       = "application/json"
+	mediaTypeMergePatch = "application/merge-patch+json"
+)
+
+// リクエストボディのJSONを dst (構造体へのポインタなど) に読み込む
+// 次の場合はエラー (*requestError) を返す
+//   - Content-Type が mediaTypes のいずれでもない (415)
+//   - ボディが上限を超えている (413, 上限は middleware.MaxBodyBytes で設定する)
+//   - ボディが空、JSONオブジェクトでない、複数のJSONを含む (400)
+//   - 未知の項目や型の異なる項目を含む (400, 項目ごとの詳細つき)
+//
+// mediaTypes を省略した場合は application/json だけを受け付ける
+func decodeJSONBody(r *http.Request, dst interface{}, mediaTypes ...string) error {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{mediaTypeJSON}
+	}
+	if err := checkContentType(r, mediaTypes); err != nil {
+		return err
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+
+	// null や配列などで対象が空のまま処理が進まないよう、JSONオブジェクトに限定する
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return &requestError{status: http.StatusBadRequest, message: "request body is required"}
+	}
+	if trimmed[0] != '{' {
+		return &requestError{status: http.StatusBadRequest, message: "request body must be a JSON object"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	// 1つのJSONの後ろに余計なデータがないこと
+	if _, err := dec.Token(); err != io.EOF {
+		return &requestError{status: http.StatusBadRequest, message: "request body must contain a single JSON object"}
+	}
+	return nil
+}
+
+// リクエストボディを読み込む
+// ボディが上限を超えている場合は 413 のエラー (*requestError) を返す
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, &requestError{
+				status:  http.StatusRequestEntityTooLarge,
+				message: fmt.Sprintf("request body must not exceed %d bytes", maxErr.Limit),
+			}
+		}
+		return nil, &requestError{status: http.StatusBadRequest, message: "failed to read request body"}
+	}
+	return body, nil
+}
+
+// Content-Type を確認する
+func checkContentType(r *http.Request, mediaTypes []string) error {
+	unsupported := &requestError{
+		status:  http.StatusUnsupportedMediaType,
+		message: fmt.Sprintf("Content-Type must be %s", strings.Join(mediaTypes, " or ")),
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return unsupported
+	}
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return unsupported
+	}
+	// JSON は UTF-8 以外を受け付けない
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return unsupported
+	}
+	for _, t := range mediaTypes {
+		if mediaType == t {
+			return nil
+		}
+	}
+	return unsupported
+}
+
+// JSON の読み込みエラーを項目ごとの詳細つきのエラーに変換する
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &requestError{
+			status:  http.StatusBadRequest,
+			message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &requestError{status: http.StatusBadRequest, message: "malformed JSON: unexpected end of body"}
+	case errors.As(err, &typeErr):
+		return &requestError{
+			status:  http.StatusBadRequest,
+			message: "invalid request body",
+			details: []FieldError{{
+				Field:   typeErr.Field,
+				Code:    "invalid_type",
+				Message: fmt.Sprintf("must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value),
+			}},
+		}
+	}
+	// 未知の項目は専用のエラー型がないのでメッセージから項目名を取り出す
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		return &requestError{
+			status:  http.StatusBadRequest,
+			message: "invalid request body",
+			details: []FieldError{{Field: field, Code: "unknown_field", Message: "unknown field"}},
+		}
+	}
+	return &requestError{status: http.StatusBadRequest, message: "invalid request body"}
+}
+
+// Go の型に対応する JSON の型の名前
+// model.AlbumID のような内部の型名をクライアントに見せないようにする
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	return "a valid value"
+}
@@ -2,19 +2,185 @@ package controller
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
+)
+
+// 項目ごとのエラーの詳細
+type FieldError struct {
+	Line    int    `json:"line,omitempty"` // 一括取り込み (/admin/import) の場合は行番号
+	Field   string `json:"field"`          // 項目名 (JSON のキー)
+	Code    string `json:"code"`           // 機械的に判別するためのコード
+	Message string `json:"message"`        // 人が読むためのメッセージ
+}
+
+// リクエストの形式の誤り
+// ステータスコードと項目ごとの詳細を持つ
+type requestError struct {
+	status  int
+	title   string // 空の場合は details の有無から決める
+	message string
+	details []FieldError
+	cause   error // ログにだけ出力する元のエラー
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func (e *requestError) Unwrap() error {
+	return e.cause
+}
+
+// パスパラメータの誤り
+// strconv のエラーなどの内部の文字列はクライアントに返さない
+func invalidPathParam(name string, err error) error {
+	return &requestError{
+		status:  http.StatusBadRequest,
+		message: fmt.Sprintf("invalid path param: %s must be a positive integer", name),
+		cause:   err,
+	}
+}
+
+// クエリパラメータの誤り
+// message は "must be ..." のように項目名を含めずに渡す
+func invalidQueryParam(name, code, message string) error {
+	return &requestError{
+		status:  http.StatusBadRequest,
+		title:   "Invalid query parameter",
+		message: fmt.Sprintf("invalid query param: %s %s", name, message),
+		details: []FieldError{{Field: name, Code: code, Message: message}},
+	}
+}
+
+// エラーレスポンスの Content-Type
+const (
+	mediaTypeProblem = "application/problem+json"
+	mediaTypeLegacy  = "application/json"
+)
+
+// エラーの種類 (problem+json の type)
+// 個別の種類がないエラーは about:blank とし、title は HTTP ステータスの説明にする
+const (
+	problemTypeDefault        = "about:blank"
+	problemTypeInvalidRequest = "/problems/invalid-request"
+	problemTypeValidation     = "/problems/validation-failed"
 )
 
+// RFC 7807 の Problem Details
+// errors と request_id は拡張メンバー
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
 // エラーが発生したときのレスポンス処理をここで行う
+// message はそのままクライアントに返すので、内部のエラーの文字列を渡さないこと
 func errorHandler(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
-	log.Printf("error: %s\n", message)
+	writeProblem(w, r, &Problem{Type: problemTypeDefault, Status: statusCode, Detail: message}, nil)
+}
+
+// ハンドラの外 (ミドルウェアなど) からエラーレスポンスを返す
+// コントローラと同じ形式で出力する
+func WriteError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	errorHandler(w, r, statusCode, message)
+}
+
+// サービス・リポジトリから返ってきたエラーをレスポンスに変換する
+// サーバーエラーの内容はログにだけ出力し、クライアントには返さない
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	p := &Problem{Type: problemTypeDefault, Status: statusCodeOf(err)}
+
+	var reqErr *requestError
+	var valErrs ValidationErrors
+	switch {
+	case errors.As(err, &reqErr):
+		p.Detail = reqErr.message
+		if len(reqErr.details) > 0 {
+			p.Type = problemTypeInvalidRequest
+			p.Title = "Invalid request body"
+			p.Errors = reqErr.details
+		}
+		if reqErr.title != "" {
+			p.Title = reqErr.title
+		}
+	case errors.As(err, &valErrs):
+		p.Type = problemTypeValidation
+		p.Title = "Validation failed"
+		p.Detail = "validation failed"
+		p.Errors = valErrs
+	case p.Status < http.StatusInternalServerError:
+		// ドメインエラーのメッセージはサービス・リポジトリで組み立てたもの (例: "singer 5: not found")
+		p.Detail = err.Error()
+	default:
+		p.Detail = "internal server error"
+	}
+	writeProblem(w, r, p, err)
+}
 
-	type ErrorMessage struct {
-		Message string `json:"message"`
+// エラーレスポンスを出力する
+// Accept で application/json だけを指定したクライアントには従来の {"message": ...} 形式で返す
+func writeProblem(w http.ResponseWriter, r *http.Request, p *Problem, cause error) {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
 	}
+	p.Instance = r.URL.Path
+	p.RequestID = logging.RequestID(r.Context())
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(&ErrorMessage{Message: message})
+	// リクエストIDが付与されたロガーで出力する
+	level := slog.LevelInfo
+	if p.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	attrs := []interface{}{"status", p.Status, "detail", p.Detail}
+	if cause != nil {
+		attrs = append(attrs, "error", cause.Error())
+	}
+	logging.FromContext(r.Context()).Log(r.Context(), level, "error response", attrs...)
+
+	if negotiateMediaType(r, mediaTypeProblem, mediaTypeLegacy) == mediaTypeLegacy {
+		type ErrorMessage struct {
+			Message string       `json:"message"`
+			Errors  []FieldError `json:"errors,omitempty"`
+		}
+
+		w.Header().Set("Content-Type", mediaTypeLegacy)
+		w.WriteHeader(p.Status)
+		json.NewEncoder(w).Encode(&ErrorMessage{Message: p.Detail, Errors: p.Errors})
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeProblem)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// ドメインエラーに対応するHTTPステータスコード
+// 該当しないエラーはすべてサーバーエラーとして扱う
+func statusCodeOf(err error) int {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		return reqErr.status
+	case errors.Is(err, apperr.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperr.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, apperr.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, apperr.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
 }
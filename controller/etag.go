@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// エンティティタグ (ETag) と条件付きリクエスト
+// タグはリソースの種類・ID・バージョンから作る強いタグで、ほかのリソースのタグと一致することはない
+// (例: 歌手1のバージョン2は "singer-1-v2")
+// 関連リソースを埋め込む表現のタグは、リソースのタグに関連リソースの値を "+" でつなげる
+// (例: 歌手を含む GET /albums/{id} は "album-1-v3+..."、トラックを含める場合は tracksVersion の値も含める)
+
+// 関連リソースの値を表す部分の長さ (32ビットのハッシュの16進数)
+const relatedLength = 8
+
+// エンティティタグを作る
+func entityTag(resource string, version int) string {
+	return fmt.Sprintf(`"%s-v%d"`, resource, version)
+}
+
+// 歌手のタグ
+func singerTag(id model.SingerID, version int) string {
+	return entityTag(fmt.Sprintf("singer-%d", id), version)
+}
+
+// アルバムのタグ
+func albumTag(id model.AlbumID, version int) string {
+	return entityTag(fmt.Sprintf("album-%d", id), version)
+}
+
+// トラックのタグ
+func trackTag(albumID model.AlbumID, number int, version int) string {
+	return entityTag(fmt.Sprintf("track-%d-%d", albumID, number), version)
+}
+
+// 関連リソースを埋め込んだ表現のタグを作る
+// 関連リソースが変わると If-None-Match では一致しなくなるが、リソースのタグをそのまま先頭に残すので、
+// If-Match ではリソースのタグと一致する (関連リソースの更新で書き込みが失敗することはない)
+func withRelated(tag string, related ...int) string {
+	h := fnv.New32a()
+	for _, v := range related {
+		fmt.Fprintf(h, "%d;", v)
+	}
+	return fmt.Sprintf(`%s+%0*x"`, strings.TrimSuffix(tag, `"`), relatedLength, h.Sum32())
+}
+
+// If-Match で指定されたタグ t が現在のリソースのタグ current か、その表現のタグ (withRelated) と一致するか
+func matchesTag(t, current string) bool {
+	if t == current {
+		return true
+	}
+	related, ok := strings.CutPrefix(t, strings.TrimSuffix(current, `"`)+"+")
+	if !ok || len(related) != relatedLength+1 || !strings.HasSuffix(related, `"`) {
+		return false
+	}
+	_, err := strconv.ParseUint(related[:relatedLength], 16, 32)
+	return err == nil
+}
+
+// トラックを含む表現のタグに埋め込む値
+// トラックには全体のバージョンがないので、追加・削除・更新のいずれでも変わるよう
+// トラック番号・バージョン・更新日時から作る
+func tracksVersion(tracks []*model.Track) int {
+	h := fnv.New32a()
+	for _, t := range tracks {
+		fmt.Fprintf(h, "%d/%d/%d;", t.Number, t.Version, t.UpdatedAt.UnixNano())
+	}
+	return int(h.Sum32())
+}
+
+// レスポンスに ETag を設定する
+func setETag(w http.ResponseWriter, tag string) {
+	w.Header().Set("ETag", tag)
+}
+
+// If-Match の条件
+// 指定がない場合と "*" の場合は nil (条件なし)
+type ifMatch []string
+
+// If-Match をタグの一覧として読み込む
+func parseIfMatch(r *http.Request) (ifMatch, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	var tags ifMatch
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		// 空の要素は読み飛ばす (RFC 9110 5.6.1)
+		if t == "" {
+			continue
+		}
+		opaque := strings.TrimPrefix(t, "W/")
+		if len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"' {
+			return nil, &requestError{
+				status:  http.StatusBadRequest,
+				message: "If-Match must be \"*\" or a list of quoted entity tags",
+			}
+		}
+		tags = append(tags, t)
+	}
+	if len(tags) == 0 {
+		return nil, &requestError{
+			status:  http.StatusBadRequest,
+			message: "If-Match must be \"*\" or a list of quoted entity tags",
+		}
+	}
+	return tags, nil
+}
+
+// 現在のリソースのタグ current がいずれかのタグと一致しない場合は apperr.ErrPreconditionFailed を返す
+// If-Match は強い比較なので弱いタグは一致しない
+func (m ifMatch) check(current string) error {
+	if m == nil {
+		return nil
+	}
+	for _, t := range m {
+		if matchesTag(t, current) {
+			return nil
+		}
+	}
+	return fmt.Errorf("entity tag %s does not match If-Match: %w", current, apperr.ErrPreconditionFailed)
+}
+
+// If-Match を現在のリソースと比較し、書き込むときに比較するバージョンを返す (条件がない場合は 0)
+// current は現在のリソースのバージョンとタグを返す
+// 比較してから書き込むまでのほかの更新は、書き込むときのバージョンの比較で検出する
+func ifMatchVersion(r *http.Request, current func() (version int, tag string, err error)) (int, error) {
+	m, err := parseIfMatch(r)
+	if err != nil || m == nil {
+		return 0, err
+	}
+	version, tag, err := current()
+	if err != nil {
+		return 0, err
+	}
+	if err := m.check(tag); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// If-None-Match が現在のタグと一致するか (弱い比較)
+func ifNoneMatch(r *http.Request, tag string) bool {
+	value := r.Header.Get("If-None-Match")
+	if value == "" {
+		return false
+	}
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// 304 Not Modified を返す
+func writeNotModified(w http.ResponseWriter, tag string) {
+	setETag(w, tag)
+	w.WriteHeader(http.StatusNotModified)
+}
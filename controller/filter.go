@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// GET /singers の検索条件を読み込む
+// name: 名前の部分一致, include_deleted: 論理削除した歌手も含める, updated_since: 指定した日時以降に更新された歌手
+// 誤りがある場合は項目ごとの詳細を持つ 400 Bad Request のエラーを返す (以下の関数も同じ)
+func parseSingerFilter(r *http.Request) (repository.SingerFilter, error) {
+	q := r.URL.Query()
+	includeDeleted, err := parseIncludeDeleted(r)
+	if err != nil {
+		return repository.SingerFilter{}, err
+	}
+	updatedSince, err := parseUpdatedSince(r)
+	if err != nil {
+		return repository.SingerFilter{}, err
+	}
+	return repository.SingerFilter{
+		NameContains:   q.Get("name"),
+		IncludeDeleted: includeDeleted,
+		UpdatedSince:   updatedSince,
+	}, nil
+}
+
+// GET /albums の検索条件を読み込む
+// singer_id: 歌手ID, title_contains: タイトルの部分一致, include_deleted: 論理削除したアルバムも含める,
+// updated_since: 指定した日時以降に更新されたアルバム,
+// released_after, released_before: 発売日がこの日 (YYYY-MM-DD) 以降・以前のアルバム, genre: ジャンル, format: 形態
+func parseAlbumFilter(r *http.Request) (repository.AlbumFilter, error) {
+	q := r.URL.Query()
+	filter := repository.AlbumFilter{
+		TitleContains: q.Get("title_contains"),
+	}
+	includeDeleted, err := parseIncludeDeleted(r)
+	if err != nil {
+		return filter, err
+	}
+	filter.IncludeDeleted = includeDeleted
+	if filter.UpdatedSince, err = parseUpdatedSince(r); err != nil {
+		return filter, err
+	}
+	if v := q.Get("singer_id"); v != "" {
+		singerID, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, invalidQueryParam("singer_id", codeInvalidFormat, "must be a positive integer")
+		}
+		if singerID < 1 {
+			return filter, invalidQueryParam("singer_id", codeOutOfRange, "must be a positive integer")
+		}
+		filter.SingerID = model.SingerID(singerID)
+	}
+	if filter.ReleasedAfter, err = parseDate(r, "released_after"); err != nil {
+		return filter, err
+	}
+	if filter.ReleasedBefore, err = parseDate(r, "released_before"); err != nil {
+		return filter, err
+	}
+	filter.Genre = normalizeGenre(q.Get("genre"))
+	if v := q.Get("format"); v != "" {
+		filter.Format = model.AlbumFormat(strings.ToLower(v))
+		if !filter.Format.Valid() {
+			return filter, invalidQueryParam("format", codeInvalidValue, "must be one of: cd, vinyl, digital")
+		}
+	}
+	return filter, nil
+}
+
+// GET /albums, GET /albums/{id} の include を読み込み、トラックを含めるかを返す
+// include: 結合する関連リソースのカンマ区切りのリスト (現在は tracks のみ)
+func parseIncludeTracks(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("include")
+	if v == "" {
+		return false, nil
+	}
+	tracks := false
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "tracks":
+			tracks = true
+		default:
+			return false, invalidQueryParam("include", codeInvalidValue, "must be a comma-separated list of: tracks")
+		}
+	}
+	return tracks, nil
+}
+
+// 管理用の include_deleted フラグを読み込む
+func parseIncludeDeleted(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("include_deleted")
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, invalidQueryParam("include_deleted", codeInvalidValue, "must be true or false")
+	}
+	return b, nil
+}
+
+// updated_since を RFC 3339 の日時として読み込む
+func parseUpdatedSince(r *http.Request) (time.Time, error) {
+	v := r.URL.Query().Get("updated_since")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, invalidQueryParam("updated_since", codeInvalidFormat, "must be an RFC 3339 timestamp (e.g. 2024-01-01T00:00:00Z)")
+	}
+	return t, nil
+}
+
+// 日付のクエリパラメータを YYYY-MM-DD として読み込む
+func parseDate(r *http.Request, name string) (string, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return "", nil
+	}
+	if _, err := time.Parse("2006-01-02", v); err != nil || len(v) != len("2006-01-02") {
+		return "", invalidQueryParam(name, codeInvalidFormat, "must be a date in the form YYYY-MM-DD")
+	}
+	return v, nil
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+)
+
+// PATCH のリクエストボディ (JSON Merge Patch, RFC 7396) を読み込む
+// パッチはJSONオブジェクトでなければならない
+func readMergePatch(r *http.Request) (map[string]interface{}, error) {
+	var patch map[string]interface{}
+	if err := decodeJSONBody(r, &patch, mediaTypeMergePatch, mediaTypeJSON); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// リポジトリが設定する読み取り専用の項目 (パッチで指定できない)
+var readOnlyFields = []string{"created_at", "updated_at", "deleted_at"}
+
+// target (構造体へのポインタ) に JSON Merge Patch を適用する
+// パッチで null を指定した項目はゼロ値になる
+// POST, PUT と同じく未知の項目は 400 (unknown_field) とし、読み取り専用の項目も 400 (read_only) とする
+func applyMergePatch(target interface{}, patch map[string]interface{}) error {
+	var details []FieldError
+	for _, field := range readOnlyFields {
+		if _, ok := patch[field]; ok {
+			details = append(details, FieldError{Field: field, Code: codeReadOnly, Message: "is read-only"})
+		}
+	}
+	if len(details) > 0 {
+		return &requestError{status: http.StatusBadRequest, message: "invalid request body", details: details}
+	}
+
+	// 現在の値をJSONの汎用表現に変換する
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergeJSON(doc, patch))
+	if err != nil {
+		return err
+	}
+
+	// 削除された項目がゼロ値になるように、一度ゼロ値に戻してから読み込む
+	v := reflect.ValueOf(target).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return ValidationErrors{{
+				Field:   typeErr.Field,
+				Code:    "invalid_type",
+				Message: fmt.Sprintf("must be %s, got %s", jsonTypeName(typeErr.Type), typeErr.Value),
+			}}
+		}
+		var reqErr *requestError
+		if errors.As(decodeError(err), &reqErr) && len(reqErr.details) > 0 {
+			return reqErr
+		}
+		return fmt.Errorf("invalid patch: %s: %w", err.Error(), apperr.ErrValidation)
+	}
+	return nil
+}
+
+// RFC 7396 の MergePatch アルゴリズム
+func mergeJSON(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		// オブジェクト以外はそのまま置き換える
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeJSON(t[k], v)
+	}
+	return t
+}
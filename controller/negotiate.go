@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Accept ヘッダから offers のうち最も優先度の高いものを選ぶ
+// 優先度が同じ場合や Accept がない場合は offers の先頭を返す
+func negotiateMediaType(r *http.Request, offers ...string) string {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offers[0]
+	}
+
+	best, bestQ := offers[0], -1.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	// どれも受け付けられない場合も、エラーを返せるように先頭を返す
+	if bestQ <= 0 {
+		return offers[0]
+	}
+	return best
+}
+
+// Accept ヘッダの中で offer に一致する範囲の q 値を返す
+// 一致する範囲が複数ある場合は、より具体的な範囲 (type/subtype > type/* > */*) を優先する
+func acceptQuality(accept []string, offer string) float64 {
+	offerType, _, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			s := -1
+			switch {
+			case mediaRange == offer:
+				s = 2
+			case mediaRange == offerType+"/*":
+				s = 1
+			case mediaRange == "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			specificity = s
+			q = 1.0
+			if v, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return q
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+const (
+	// offset か cursor を指定して limit を指定しない場合の取得件数
+	// (どれも指定しない場合は従来どおりすべて返す)
+	defaultListLimit = 100
+	// limit に指定できる最大値
+	maxListLimit = 1000
+)
+
+// 一覧取得のクエリパラメータ (limit, offset, cursor, sort, order) を読み込む
+// sortKeys は並び替えに使用できるキー
+// 誤りがある場合は項目ごとの詳細を持つ 400 Bad Request のエラーを返す
+func parseListOptions(r *http.Request, sortKeys []string) (repository.ListOptions, error) {
+	q := r.URL.Query()
+	opts := repository.ListOptions{}
+	if q.Get("offset") != "" || q.Get("cursor") != "" {
+		opts.Limit = defaultListLimit
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, invalidQueryParam("limit", codeInvalidFormat, fmt.Sprintf("must be an integer between 1 and %d", maxListLimit))
+		}
+		if limit < 1 || limit > maxListLimit {
+			return opts, invalidQueryParam("limit", codeOutOfRange, fmt.Sprintf("must be between 1 and %d", maxListLimit))
+		}
+		opts.Limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, invalidQueryParam("offset", codeInvalidFormat, "must be a non-negative integer")
+		}
+		if offset < 0 {
+			return opts, invalidQueryParam("offset", codeOutOfRange, "must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+
+	if v := q.Get("sort"); v != "" {
+		if !contains(sortKeys, v) {
+			return opts, invalidQueryParam("sort", codeInvalidValue, "must be one of: "+strings.Join(sortKeys, ", "))
+		}
+		opts.Sort = v
+	}
+	switch order := repository.SortOrder(q.Get("order")); order {
+	case "":
+	case repository.OrderAsc, repository.OrderDesc:
+		opts.Order = order
+	default:
+		return opts, invalidQueryParam("order", codeInvalidValue, "must be one of: asc, desc")
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if opts.Offset > 0 {
+			return opts, invalidQueryParam("cursor", codeExclusive, "cannot be used together with offset")
+		}
+		cursor, err := repository.ParseCursor(v)
+		if err != nil {
+			return opts, invalidQueryParam("cursor", codeInvalidFormat, "is malformed")
+		}
+		// 別の並び替えキーで作られたカーソルは使えない
+		if cursor.Sort != opts.SortKey() {
+			return opts, invalidQueryParam("cursor", codeMismatch, "does not match sort")
+		}
+		opts.After = cursor
+	}
+	return opts, nil
+}
+
+// 次のページがある場合に Link ヘッダーと X-Next-Cursor ヘッダーを設定する
+// offset で取得した場合は次のページも offset で、それ以外はカーソルで案内する
+func writePaginationHeaders(w http.ResponseWriter, r *http.Request, opts repository.ListOptions, next *repository.Cursor) {
+	if next == nil {
+		return
+	}
+	token := next.Encode()
+
+	u := *r.URL
+	q := u.Query()
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset+opts.Limit))
+	} else {
+		q.Set("cursor", token)
+	}
+	u.RawQuery = q.Encode()
+
+	w.Header().Set("X-Next-Cursor", token)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
 	"github.com/pulse227/server-recruit-challenge-sample/service"
 )
 
@@ -22,13 +23,27 @@ func NewSingerController(s service.SingerService) *singerController {
 
 // GET /singers のハンドラー
 func (c *singerController) GetSingerListHandler(w http.ResponseWriter, r *http.Request) {
-	singers, err := c.service.GetSingerListService(r.Context())
+	// ページング・並び替えのパラメータの取得
+	opts, err := parseListOptions(r, repository.SingerSortKeys)
 	if err != nil {
-		// エラーの場合サーバーエラーを出して終了
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
+		return
+	}
+
+	// 検索条件の取得
+	filter, err := parseSingerFilter(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	singers, next, err := c.service.GetSingerListService(r.Context(), filter, opts)
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 	// レスポンスの作成
+	writePaginationHeaders(w, r, opts, next)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	// JSONレスポンス
@@ -41,17 +56,23 @@ func (c *singerController) GetSingerDetailHandler(w http.ResponseWriter, r *http
 	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		// エラー処理
-		err = fmt.Errorf("invalid path param: %w", err)
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, invalidPathParam("id", err))
 		return
 	}
 	// 特定の歌手の呼び出し
 	singer, err := c.service.GetSingerService(r.Context(), model.SingerID(singerID))
 	if err != nil {
-		errorHandler(w, r, 500, err.Error())
+		handleError(w, r, err)
+		return
+	}
+	// クライアントが持っている表現が最新なら本文を返さない
+	tag := singerTag(singer.ID, singer.Version)
+	if ifNoneMatch(r, tag) {
+		writeNotModified(w, tag)
 		return
 	}
 	// レスポンス作成
+	setETag(w, tag)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(singer)
@@ -59,21 +80,129 @@ func (c *singerController) GetSingerDetailHandler(w http.ResponseWriter, r *http
 
 // POST /singers のハンドラー
 func (c *singerController) PostSingerHandler(w http.ResponseWriter, r *http.Request) {
-	var singer *model.Singer
+	singer := &model.Singer{}
 	// リクエストボディのパース・エラーチェック
-	if err := json.NewDecoder(r.Body).Decode(&singer); err != nil {
-		err = fmt.Errorf("invalid body param: %w", err) // 文字列エラーの作成
-		errorHandler(w, r, 400, err.Error())            // 400 Bad Requestを返す
+	if err := decodeJSONBody(r, singer); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// リクエストのバリデーション
+	validation := &SingersValidation{}
+	if err := validation.ValidateSinger(singer); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// 歌手データの保存
 	if err := c.service.PostSingerService(r.Context(), singer); err != nil {
-		errorHandler(w, r, 500, err.Error()) // 500 Internal Server Errorを返す
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンスの作成 (201 Created と作成した歌手のURL)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/singers/%d", singer.ID))
+	setETag(w, singerTag(singer.ID, singer.Version))
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(singer)
+}
+
+// PUT /singers/{id} のハンドラー
+func (c *singerController) PutSingerHandler(w http.ResponseWriter, r *http.Request) {
+	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+	// 更新するバージョンの指定 (If-Match)
+	version, err := c.ifMatchVersion(r, model.SingerID(singerID))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	singer := &model.Singer{}
+	// リクエストボディのパース・エラーチェック
+	if err := decodeJSONBody(r, singer); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// ボディのIDは省略可能だが、指定する場合はパスと一致させる
+	var val validator
+	if singer.ID != 0 && singer.ID != model.SingerID(singerID) {
+		val.add("id", codeMismatch, "must match the id in the path")
+	}
+	singer.ID = model.SingerID(singerID)
+	singer.Version = version
+
+	// リクエストのバリデーション
+	validation := &SingersValidation{}
+	val.merge(validation.ValidateSinger(singer))
+	if err := val.err(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// 歌手データの更新
+	if err := c.service.PutSingerService(r.Context(), singer); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンスの作成
+	setETag(w, singerTag(singer.ID, singer.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(singer)
+}
+
+// PATCH /singers/{id} のハンドラー (JSON Merge Patch)
+func (c *singerController) PatchSingerHandler(w http.ResponseWriter, r *http.Request) {
+	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	// 更新するバージョンの指定 (If-Match)
+	precondition, err := parseIfMatch(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	patch, err := readMergePatch(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// パッチを適用した結果に対してバリデーションを行う
+	validation := &SingersValidation{}
+
+	// 歌手データの部分更新
+	singer, err := c.service.PatchSingerService(r.Context(), model.SingerID(singerID), func(singer *model.Singer) error {
+		if err := precondition.check(singerTag(singer.ID, singer.Version)); err != nil {
+			return err
+		}
+		if err := applyMergePatch(singer, patch); err != nil {
+			return err
+		}
+		var val validator
+		if singer.ID != model.SingerID(singerID) {
+			val.add("id", codeImmutable, "cannot be changed")
+		}
+		val.merge(validation.ValidateSinger(singer))
+		return val.err()
+	})
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// レスポンスの作成
+	setETag(w, singerTag(singer.ID, singer.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(singer)
@@ -84,16 +213,54 @@ func (c *singerController) DeleteSingerHandler(w http.ResponseWriter, r *http.Re
 	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		// エラーチェックを行い エラーの場合400 Bad Requestを返す
-		err = fmt.Errorf("invalid path param: %w", err)
-		errorHandler(w, r, 400, err.Error())
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+	// 削除するバージョンの指定 (If-Match)
+	version, err := c.ifMatchVersion(r, model.SingerID(singerID))
+	if err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	// 歌手データの削除
-	if err := c.service.DeleteSingerService(r.Context(), model.SingerID(singerID)); err != nil {
-		errorHandler(w, r, 500, err.Error())
+	if err := c.service.DeleteSingerService(r.Context(), model.SingerID(singerID), version); err != nil {
+		handleError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(204) // 204 No Contentを返す
 }
+
+// POST /singers/{id}:restore のハンドラー
+// 論理削除した歌手を元に戻す
+func (c *singerController) RestoreSingerHandler(w http.ResponseWriter, r *http.Request) {
+	singerID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	singer, err := c.service.RestoreSingerService(r.Context(), model.SingerID(singerID))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンスの作成
+	setETag(w, singerTag(singer.ID, singer.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(singer)
+}
+
+// If-Match を現在の歌手のタグと比較し、書き込むときに比較するバージョンを返す
+func (c *singerController) ifMatchVersion(r *http.Request, singerID model.SingerID) (int, error) {
+	return ifMatchVersion(r, func() (int, string, error) {
+		singer, err := c.service.GetSingerService(r.Context(), singerID)
+		if err != nil {
+			return 0, "", err
+		}
+		return singer.Version, singerTag(singer.ID, singer.Version), nil
+	})
+}
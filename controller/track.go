@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
+)
+
+type trackController struct {
+	service service.TrackService
+}
+
+// コンストラクタ
+func NewTrackController(service service.TrackService) *trackController {
+	return &trackController{service: service}
+}
+
+// GET /albums/{id}/tracks のハンドラ
+// トラック番号順に返す
+func (c *trackController) GetTrackListHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	tracks, err := c.service.GetTrackListService(r.Context(), model.AlbumID(albumID))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// レスポンスの作成
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	// JSONレスポンス
+	json.NewEncoder(w).Encode(tracks)
+}
+
+// GET /albums/{id}/tracks/{number} のハンドラ
+func (c *trackController) GetTrackDetailHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, number, err := trackPathParams(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	track, err := c.service.GetTrackService(r.Context(), albumID, number)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// クライアントが持っている表現が最新なら本文を返さない
+	tag := trackTag(track.AlbumID, track.Number, track.Version)
+	if ifNoneMatch(r, tag) {
+		writeNotModified(w, tag)
+		return
+	}
+	// レスポンス作成
+	setETag(w, tag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(track)
+}
+
+// POST /albums/{id}/tracks のハンドラ
+// album_id はパスから設定し、number を省略した場合は最後のトラックの次の番号にする
+func (c *trackController) PostTrackHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		// エラー処理
+		handleError(w, r, invalidPathParam("id", err))
+		return
+	}
+
+	track := &model.Track{}
+
+	// リクエストのパース
+	if err := decodeJSONBody(r, track); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// ボディの album_id は省略可能だが、指定する場合はパスと一致させる
+	var val validator
+	if track.AlbumID != 0 && track.AlbumID != model.AlbumID(albumID) {
+		val.add("album_id", codeMismatch, "must match the album id in the path")
+	}
+	track.AlbumID = model.AlbumID(albumID)
+
+	// リクエストのバリデーション
+	validation := &TracksValidation{}
+	val.merge(validation.ValidateTrack(track))
+	if err := val.err(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// トラックの作成
+	if err := c.service.PostTrackService(r.Context(), track); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成 (201 Created と作成したトラックのURL)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/albums/%d/tracks/%d", track.AlbumID, track.Number))
+	setETag(w, trackTag(track.AlbumID, track.Number, track.Version))
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(track)
+}
+
+// PUT /albums/{id}/tracks/{number} のハンドラ
+func (c *trackController) PutTrackHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, number, err := trackPathParams(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// 更新するバージョンの指定 (If-Match)
+	version, err := c.ifMatchVersion(r, albumID, number)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	track := &model.Track{}
+
+	// リクエストのパース
+	if err := decodeJSONBody(r, track); err != nil {
+		handleError(w, r, err)
+		return
+	}
+	// ボディの album_id と number は省略可能だが、指定する場合はパスと一致させる
+	var val validator
+	if track.AlbumID != 0 && track.AlbumID != albumID {
+		val.add("album_id", codeMismatch, "must match the album id in the path")
+	}
+	if track.Number != 0 && track.Number != number {
+		val.add("number", codeMismatch, "must match the track number in the path")
+	}
+	track.AlbumID = albumID
+	track.Number = number
+	track.Version = version
+
+	// リクエストのバリデーション
+	validation := &TracksValidation{}
+	val.merge(validation.ValidateTrack(track))
+	if err := val.err(); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// トラックの更新
+	if err := c.service.PutTrackService(r.Context(), track); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成
+	setETag(w, trackTag(track.AlbumID, track.Number, track.Version))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(track)
+}
+
+// DELETE /albums/{id}/tracks/{number} のハンドラ
+func (c *trackController) DeleteTrackHandler(w http.ResponseWriter, r *http.Request) {
+	// パスパラメータの取得
+	albumID, number, err := trackPathParams(r)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// 削除するバージョンの指定 (If-Match)
+	version, err := c.ifMatchVersion(r, albumID, number)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// トラックの削除
+	if err := c.service.DeleteTrackService(r.Context(), albumID, number, version); err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	// レスポンス作成
+	w.WriteHeader(204)
+}
+
+// パスパラメータのアルバムIDとトラック番号を取得する
+func trackPathParams(r *http.Request) (model.AlbumID, int, error) {
+	vars := mux.Vars(r)
+	albumID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, invalidPathParam("id", err)
+	}
+	number, err := strconv.Atoi(vars["number"])
+	if err != nil {
+		return 0, 0, invalidPathParam("number", err)
+	}
+	return model.AlbumID(albumID), number, nil
+}
+
+// If-Match を現在のトラックのタグと比較し、書き込むときに比較するバージョンを返す
+func (c *trackController) ifMatchVersion(r *http.Request, albumID model.AlbumID, number int) (int, error) {
+	return ifMatchVersion(r, func() (int, string, error) {
+		track, err := c.service.GetTrackService(r.Context(), albumID, number)
+		if err != nil {
+			return 0, "", err
+		}
+		return track.Version, trackTag(track.AlbumID, track.Number, track.Version), nil
+	})
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"golang.org/x/text/unicode/norm"
+)
+
+// バリデーションエラーのコード
+const (
+	codeRequired      = "required"       // 必須項目がない
+	codeTooLong       = "too_long"       // 文字数や要素数が上限を超えている
+	codeInvalidChars  = "invalid_chars"  // 制御文字などを含む
+	codeOutOfRange    = "out_of_range"   // 数値が範囲外
+	codeInvalidFormat = "invalid_format" // 決められた形式に合わない
+	codeMismatch      = "mismatch"       // パスなど他の値と一致しない
+	codeImmutable     = "immutable"      // 変更できない項目を変更しようとした
+	codeReadOnly      = "read_only"      // リポジトリが設定する項目を指定した
+	codeInvalidValue  = "invalid_value"  // 決められた値のいずれでもない
+	codeDuplicate     = "duplicate"      // 他の要素と重複している
+	codeExclusive     = "exclusive"      // 同時に指定できない項目と一緒に指定した
+)
+
+// バリデーションエラー
+// 最初の1件で止めずに、すべての違反をまとめて返す
+// apperr.ErrValidation をラップしているので 422 Unprocessable Entity になる
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) Unwrap() error {
+	return apperr.ErrValidation
+}
+
+// 違反を集める
+type validator struct {
+	errs ValidationErrors
+}
+
+// 違反を追加する
+func (v *validator) add(field, code, message string) {
+	v.errs = append(v.errs, FieldError{Field: field, Code: code, Message: message})
+}
+
+// 別のバリデーションの結果 (ValidationErrors) を追加する
+func (v *validator) merge(err error) {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		v.errs = append(v.errs, errs...)
+	}
+}
+
+// 違反がある場合は ValidationErrors を返す
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// 必須の文字列項目を確認する
+// 値は確認の前に normalizeText で正規化し、maxLen は文字 (rune) 数で数える
+func (v *validator) text(field string, value *string, maxLen int) {
+	*value = normalizeText(*value)
+	switch {
+	case *value == "":
+		v.add(field, codeRequired, "is required")
+	case utf8.RuneCountInString(*value) > maxLen:
+		v.add(field, codeTooLong, fmt.Sprintf("must be at most %d characters", maxLen))
+	case strings.IndexFunc(*value, unicode.IsControl) >= 0:
+		v.add(field, codeInvalidChars, "must not contain control characters")
+	}
+}
+
+// 省略可能な文字列項目を確認する
+// 正規化した結果が空文字列の場合は省略したものとして扱う
+func (v *validator) optionalText(field string, value *string, maxLen int) {
+	*value = normalizeText(*value)
+	if *value == "" {
+		return
+	}
+	v.text(field, value, maxLen)
+}
+
+// 文字列を正規化する
+// 前後の空白を取り除き、見た目が同じ文字列が同じ値になるよう NFC に揃える
+func normalizeText(s string) string {
+	return norm.NFC.String(strings.TrimSpace(s))
+}
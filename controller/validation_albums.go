@@ -1,26 +1,152 @@
 package controller
 
 import (
-	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 )
 
-type AlbumsValidation struct{}
+// アルバムのタイトルの最大文字数
+const maxAlbumTitleLength = 200
+
+// アルバムに参加できる歌手の最大数
+const maxAlbumArtists = 50
+
+// レーベル名の最大文字数
+const maxAlbumLabelLength = 200
+
+// ジャンルの最大数と、ジャンル名の最大文字数
+const (
+	maxAlbumGenres      = 10
+	maxAlbumGenreLength = 50
+)
+
+// 発売日として受け付ける形式 (ISO 8601 の日付・年月・年)
+var releaseDateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+type AlbumsValidation struct {
+	// メインの歌手がいないアルバムを受け付ける
+	// 削除方針 orphan で歌手を外したアルバムを書き出したデータの取り込みに使う
+	AllowNoPrimary bool
+}
 
 // アルバム情報のバリデーションを行う
+// タイトルは前後の空白を取り除き、NFC に正規化した値に書き換える
+// artists を省略した場合は singer_id の歌手をメインの歌手とし、
+// 指定した場合は singer_id を最初のメインの歌手にする (singer_id も指定する場合は一致させる)
+// AllowNoPrimary の場合は singer_id と artists の両方を省略したり、artists にメインの歌手を含めなかったりできる
+// 発売日・ジャンル・レーベル・形態は省略可能で、ジャンルと形態は小文字に揃える
+// エラーは ValidationErrors (apperr.ErrValidation をラップ) で返す
 func (v *AlbumsValidation) ValidateAlbum(album *model.Album) error {
+	var val validator
 
 	// パラメーターが不足している場合はエラー
-	if album.ID == 0 {
-		return errors.New("ID is required")
+	// IDは省略可能 (省略した場合はサーバーで払い出す)
+	if album.ID < 0 {
+		val.add("id", codeOutOfRange, "must be a positive integer")
+	}
+	val.text("title", &album.Title, maxAlbumTitleLength)
+	if len(album.Artists) == 0 {
+		switch {
+		case album.SingerID == 0 && !v.AllowNoPrimary:
+			val.add("singer_id", codeRequired, "is required")
+		case album.SingerID < 0:
+			val.add("singer_id", codeOutOfRange, "must be a positive integer")
+		}
+	} else {
+		validateArtists(&val, album, v.AllowNoPrimary)
+	}
+	album.SyncArtists()
+
+	if album.ReleaseDate != "" && !validReleaseDate(album.ReleaseDate) {
+		val.add("release_date", codeInvalidFormat, "must be an ISO 8601 date (YYYY-MM-DD, YYYY-MM or YYYY)")
+	}
+	validateGenres(&val, album)
+	val.optionalText("label", &album.Label, maxAlbumLabelLength)
+	album.Format = model.AlbumFormat(strings.ToLower(strings.TrimSpace(string(album.Format))))
+	if album.Format != "" && !album.Format.Valid() {
+		val.add("format", codeInvalidValue, "must be one of: cd, vinyl, digital")
+	}
+
+	return val.err()
+}
+
+// アルバムに参加する歌手を確認する
+// allowNoPrimary の場合はメインの歌手がいなくてもよい
+func validateArtists(val *validator, album *model.Album, allowNoPrimary bool) {
+	if len(album.Artists) > maxAlbumArtists {
+		val.add("artists", codeTooLong, fmt.Sprintf("must have at most %d entries", maxAlbumArtists))
+		return
+	}
+	type credit struct {
+		singerID model.SingerID
+		role     model.ArtistRole
+	}
+	seen := map[credit]bool{}
+	var primary model.SingerID
+	for i, artist := range album.Artists {
+		field := fmt.Sprintf("artists[%d]", i)
+		switch {
+		case artist.SingerID == 0:
+			val.add(field+".singer_id", codeRequired, "is required")
+		case artist.SingerID < 0:
+			val.add(field+".singer_id", codeOutOfRange, "must be a positive integer")
+		}
+		if !artist.Role.Valid() {
+			val.add(field+".role", codeInvalidValue, "must be one of: primary, featured, producer")
+		}
+		c := credit{artist.SingerID, artist.Role}
+		if seen[c] {
+			val.add(field, codeDuplicate, "duplicates another entry")
+		}
+		seen[c] = true
+		if artist.Role == model.ArtistRolePrimary && primary == 0 {
+			primary = artist.SingerID
+		}
+	}
+	switch {
+	case primary == 0 && !allowNoPrimary:
+		val.add("artists", codeRequired, "must include a primary singer")
+	case album.SingerID != 0 && album.SingerID != primary:
+		val.add("singer_id", codeMismatch, "must match the first primary singer in artists")
 	}
-	if album.Title == "" {
-		return errors.New("album Title is required")
+}
+
+// 発売日の形式を確認する
+func validReleaseDate(s string) bool {
+	for _, layout := range releaseDateLayouts {
+		// 月や日を1桁で書いたものは受け付けない
+		if len(s) != len(layout) {
+			continue
+		}
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ジャンルを確認し、正規化した値に書き換える
+func validateGenres(val *validator, album *model.Album) {
+	if len(album.Genres) > maxAlbumGenres {
+		val.add("genres", codeTooLong, fmt.Sprintf("must have at most %d entries", maxAlbumGenres))
+		return
 	}
-	if album.SingerID == 0 {
-		return errors.New("SingerID is required")
+	seen := map[string]bool{}
+	for i := range album.Genres {
+		field := fmt.Sprintf("genres[%d]", i)
+		album.Genres[i] = normalizeGenre(album.Genres[i])
+		val.text(field, &album.Genres[i], maxAlbumGenreLength)
+		if album.Genres[i] != "" && seen[album.Genres[i]] {
+			val.add(field, codeDuplicate, "duplicates another entry")
+		}
+		seen[album.Genres[i]] = true
 	}
+}
 
-	return nil
+// ジャンル名を正規化する (大文字小文字を区別しないよう小文字に揃える)
+func normalizeGenre(s string) string {
+	return strings.ToLower(normalizeText(s))
 }
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// 歌手の名前 (芸名・本名・別名も同じ) の最大文字数
+const maxSingerNameLength = 100
+
+// 別名と外部サービスのIDの最大数
+const (
+	maxSingerAliases     = 20
+	maxSingerExternalIDs = 20
+)
+
+// 外部サービスのIDの最大文字数
+const maxExternalIDLength = 200
+
+// デビューした年として受け付ける範囲
+const (
+	minDebutYear = 1000
+	maxDebutYear = 9999
+)
+
+// 国コード (ISO 3166-1 alpha-2)
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// 外部サービス名 (小文字の英数字とアンダースコア)
+var externalServicePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+type SingersValidation struct{}
+
+// 歌手情報のバリデーションを行う
+// 名前は前後の空白を取り除き、NFC に正規化した値に書き換える
+// プロフィールの項目と別名は省略可能で、国コードは大文字に揃える
+// エラーは ValidationErrors (apperr.ErrValidation をラップ) で返す
+func (v *SingersValidation) ValidateSinger(singer *model.Singer) error {
+	var val validator
+
+	// IDは省略可能 (省略した場合はサーバーで払い出す)
+	if singer.ID < 0 {
+		val.add("id", codeOutOfRange, "must be a positive integer")
+	}
+	val.text("name", &singer.Name, maxSingerNameLength)
+	val.optionalText("stage_name", &singer.StageName, maxSingerNameLength)
+	val.optionalText("real_name", &singer.RealName, maxSingerNameLength)
+	singer.Country = strings.ToUpper(strings.TrimSpace(singer.Country))
+	if singer.Country != "" && !countryPattern.MatchString(singer.Country) {
+		val.add("country", codeInvalidFormat, "must be an ISO 3166-1 alpha-2 country code (e.g. JP)")
+	}
+	if singer.DebutYear != 0 && (singer.DebutYear < minDebutYear || singer.DebutYear > maxDebutYear) {
+		val.add("debut_year", codeOutOfRange, fmt.Sprintf("must be between %d and %d", minDebutYear, maxDebutYear))
+	}
+	validateAliases(&val, singer)
+	validateExternalIDs(&val, singer)
+
+	return val.err()
+}
+
+// 別名を確認し、正規化した値に書き換える
+func validateAliases(val *validator, singer *model.Singer) {
+	if len(singer.Aliases) > maxSingerAliases {
+		val.add("aliases", codeTooLong, fmt.Sprintf("must have at most %d entries", maxSingerAliases))
+		return
+	}
+	seen := map[string]bool{}
+	for i := range singer.Aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		val.text(field, &singer.Aliases[i], maxSingerNameLength)
+		if singer.Aliases[i] != "" && seen[singer.Aliases[i]] {
+			val.add(field, codeDuplicate, "duplicates another entry")
+		}
+		seen[singer.Aliases[i]] = true
+	}
+}
+
+// 外部サービスのIDを確認し、正規化した値に書き換える
+func validateExternalIDs(val *validator, singer *model.Singer) {
+	if len(singer.ExternalIDs) > maxSingerExternalIDs {
+		val.add("external_ids", codeTooLong, fmt.Sprintf("must have at most %d entries", maxSingerExternalIDs))
+		return
+	}
+	// エラーの順序が変わらないようにキーの順に確認する
+	services := make([]string, 0, len(singer.ExternalIDs))
+	for service := range singer.ExternalIDs {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		field := "external_ids." + service
+		if !externalServicePattern.MatchString(service) {
+			val.add(field, codeInvalidFormat, "service name must be lowercase letters, digits or underscores")
+			continue
+		}
+		id := singer.ExternalIDs[service]
+		val.text(field, &id, maxExternalIDLength)
+		singer.ExternalIDs[service] = id
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// トラックのタイトルの最大文字数
+const maxTrackTitleLength = 200
+
+// トラックの再生時間の上限 (秒)
+const maxTrackDurationSeconds = 24 * 60 * 60
+
+// ISRC の形式 (国コード2文字、登録者コード3文字、年2桁、番号5桁)
+var isrcPattern = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{3}[0-9]{7}$`)
+
+type TracksValidation struct{}
+
+// トラック情報のバリデーションを行う
+// タイトルは前後の空白を取り除き、NFC に正規化した値に書き換える
+// ISRC は区切りのハイフンを取り除き、大文字に揃えた値に書き換える
+// エラーは ValidationErrors (apperr.ErrValidation をラップ) で返す
+func (v *TracksValidation) ValidateTrack(track *model.Track) error {
+	var val validator
+
+	// トラック番号は省略可能 (省略した場合はアルバムの最後の番号の次を払い出す)
+	if track.Number < 0 {
+		val.add("number", codeOutOfRange, "must be a positive integer")
+	}
+	val.text("title", &track.Title, maxTrackTitleLength)
+	switch {
+	case track.DurationSeconds == 0:
+		val.add("duration_seconds", codeRequired, "is required")
+	case track.DurationSeconds < 0 || track.DurationSeconds > maxTrackDurationSeconds:
+		val.add("duration_seconds", codeOutOfRange, fmt.Sprintf("must be between 1 and %d", maxTrackDurationSeconds))
+	}
+	// ISRC は省略可能
+	track.ISRC = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(track.ISRC), "-", ""))
+	if track.ISRC != "" && !isrcPattern.MatchString(track.ISRC) {
+		val.add("isrc", codeInvalidFormat, "must be a 12-character ISRC (e.g. JPAB01234567)")
+	}
+
+	return val.err()
+}
@@ -2,9 +2,11 @@ package memorydb
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 	"github.com/pulse227/server-recruit-challenge-sample/repository"
 )
@@ -12,39 +14,118 @@ import (
 type albumRepository struct {
 	sync.RWMutex
 	albumMap map[model.AlbumID]*model.Album // キーが AlbumID、値が model.Album のマップ
+	lastID   model.AlbumID                  // 最後に払い出したID (削除されても再利用しない)
+	now      func() time.Time               // 作成・更新・削除の日時に使う現在時刻
+
+	// 歌手IDからその歌手が参加しているアルバムを引くためのインデックス
+	// albumMap を変更するときは put / remove を通して一緒に更新する
+	singerIndex map[model.SingerID]map[model.AlbumID]struct{}
 }
 
 var _ repository.AlbumRepository = (*albumRepository)(nil)
-var albumRepo *albumRepository
-
-func NewAlbumRepository() *albumRepository {
-	// 初期化されていない場合は初期化する
-	if albumRepo == nil {
-		var initMap = map[model.AlbumID]*model.Album{
-			1: {ID: 1, Title: "Alice's 1st Album", SingerID: 1},
-			2: {ID: 2, Title: "Alice's 2nd Album", SingerID: 1},
-			3: {ID: 3, Title: "Bella's 1st Album", SingerID: 2},
+var _ repository.Transactor = (*albumRepository)(nil)
+
+// 空のリポジトリを作成する
+// 初期データは seed パッケージで投入する
+func NewAlbumRepository(opts ...Option) *albumRepository {
+	return &albumRepository{
+		albumMap:    map[model.AlbumID]*model.Album{},
+		singerIndex: map[model.SingerID]map[model.AlbumID]struct{}{},
+		now:         newClock(opts),
+	}
+}
+
+// アルバムを保存し、インデックスを更新する (ロックは呼び出し側で取る)
+func (r *albumRepository) put(album *model.Album) {
+	if old, ok := r.albumMap[album.ID]; ok {
+		r.unindex(old)
+	}
+	r.albumMap[album.ID] = album
+	for _, artist := range album.Artists {
+		ids, ok := r.singerIndex[artist.SingerID]
+		if !ok {
+			ids = map[model.AlbumID]struct{}{}
+			r.singerIndex[artist.SingerID] = ids
+		}
+		ids[album.ID] = struct{}{}
+	}
+}
+
+// アルバムを削除し、インデックスを更新する (ロックは呼び出し側で取る)
+func (r *albumRepository) remove(id model.AlbumID) {
+	if old, ok := r.albumMap[id]; ok {
+		r.unindex(old)
+		delete(r.albumMap, id)
+	}
+}
+
+// インデックスからアルバムを外す
+func (r *albumRepository) unindex(album *model.Album) {
+	for _, artist := range album.Artists {
+		ids := r.singerIndex[artist.SingerID]
+		delete(ids, album.ID)
+		if len(ids) == 0 {
+			delete(r.singerIndex, artist.SingerID)
 		}
-		return &albumRepository{
-			albumMap: initMap,
+	}
+}
+
+// 指定した歌手が参加しているアルバムをインデックスから取得する (ロックは呼び出し側で取る)
+// 論理削除したアルバムも含む
+func (r *albumRepository) albumsOf(singerID model.SingerID) []*model.Album {
+	ids := r.singerIndex[singerID]
+	albums := make([]*model.Album, 0, len(ids))
+	for id := range ids {
+		albums = append(albums, r.albumMap[id])
+	}
+	return albums
+}
+
+// 論理削除したアルバムを取り除く
+func liveAlbums(albums []*model.Album) []*model.Album {
+	live := albums[:0]
+	for _, a := range albums {
+		if a.DeletedAt == nil {
+			live = append(live, a)
 		}
 	}
-	return albumRepo
+	return live
+}
+
+// アルバムの一覧を取得する
+func (r *albumRepository) GetAll(ctx context.Context, opts repository.ListOptions) ([]*model.Album, error) {
+	return r.Find(ctx, repository.AlbumFilter{}, opts)
 }
 
-// すべてのアルバムを取得する
-func (r *albumRepository) GetAll(ctx context.Context) ([]*model.Album, error) {
+// 検索条件に一致するアルバムを取得する
+func (r *albumRepository) Find(ctx context.Context, filter repository.AlbumFilter, opts repository.ListOptions) ([]*model.Album, error) {
 	// 書き込みの排他制御
 	r.RLock()
 	defer r.RUnlock()
 
-	// サイズがアルバム数のスライスを作成する
-	albums := make([]*model.Album, 0, len(r.albumMap))
-	for _, s := range r.albumMap {
-		// スライスに追加
-		albums = append(albums, s)
+	// 歌手の指定がある場合はインデックスから候補を絞り込む
+	var candidates []*model.Album
+	if filter.SingerID != 0 {
+		candidates = r.albumsOf(filter.SingerID)
+	} else {
+		candidates = make([]*model.Album, 0, len(r.albumMap))
+		for _, a := range r.albumMap {
+			candidates = append(candidates, a)
+		}
 	}
-	return albums, nil
+
+	albums := make([]*model.Album, 0)
+	for _, a := range candidates {
+		if matchAlbum(a, filter) {
+			// スライスに追加
+			albums = append(albums, a)
+		}
+	}
+	// マップの順序はランダムなので、並び替えてから返す
+	sortKey := opts.SortKey()
+	return paginate(albums, opts, func(a *model.Album) position {
+		return position{key: repository.AlbumSortValue(a, sortKey), id: int(a.ID)}
+	}), nil
 }
 
 // 指定したIDのアルバムを取得する
@@ -54,24 +135,247 @@ func (r *albumRepository) Get(ctx context.Context, id model.AlbumID) (*model.Alb
 
 	album, ok := r.albumMap[id]
 	// インデックスが見つからない場合falseを返す
-	if !ok {
-		return nil, errors.New("not found")
+	if !ok || album.DeletedAt != nil {
+		return nil, fmt.Errorf("album %d: %w", id, apperr.ErrNotFound)
 	}
 	return album, nil
 }
 
+// アルバムを追加する
+// IDが0の場合は新しいIDを払い出し、album.ID に設定する
 func (r *albumRepository) Add(ctx context.Context, album *model.Album) error {
+	defer beginWrite(ctx, r)()
 	r.Lock()
 	defer r.Unlock()
+
+	if album.ID == 0 {
+		r.lastID++
+		album.ID = r.lastID
+	} else {
+		// 指定されたIDがすでに使われている場合は上書きしない
+		if _, ok := r.albumMap[album.ID]; ok {
+			return fmt.Errorf("album %d: %w", album.ID, apperr.ErrConflict)
+		}
+		if album.ID > r.lastID {
+			r.lastID = album.ID
+		}
+	}
 	// 追加
-	r.albumMap[album.ID] = album
+	album.SyncArtists()
+	album.Version = 1
+	album.CreatedAt = r.now()
+	album.UpdatedAt = album.CreatedAt
+	album.DeletedAt = nil
+	r.put(album)
+	return nil
+}
+
+// アルバムを更新する
+// album.Version が 0 でない場合は現在のバージョンと一致するときだけ更新する
+func (r *albumRepository) Update(ctx context.Context, album *model.Album) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	current, err := r.current(album.ID, album.Version)
+	if err != nil {
+		return err
+	}
+	album.SyncArtists()
+	album.Version = current.Version + 1
+	album.CreatedAt = current.CreatedAt
+	album.UpdatedAt = r.now()
+	album.DeletedAt = nil
+	r.put(album)
 	return nil
 }
 
-func (r *albumRepository) Delete(ctx context.Context, id model.AlbumID) error {
+// アルバムを論理削除する
+// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+func (r *albumRepository) Delete(ctx context.Context, id model.AlbumID, version int) error {
+	defer beginWrite(ctx, r)()
 	r.Lock()
 	defer r.Unlock()
+	current, err := r.current(id, version)
+	if err != nil {
+		return err
+	}
 	// 削除
-	delete(r.albumMap, id)
+	r.softDelete(current, r.now())
+	return nil
+}
+
+// アルバムに削除日時を設定する (ロックは呼び出し側で取る)
+func (r *albumRepository) softDelete(album *model.Album, now time.Time) {
+	// 取得済みのポインタに影響しないようにコピーを書き換える
+	deleted := *album
+	deleted.DeletedAt = &now
+	deleted.UpdatedAt = now
+	deleted.Version++
+	r.put(&deleted)
+}
+
+// 論理削除を取り消す
+func (r *albumRepository) Restore(ctx context.Context, id model.AlbumID) (*model.Album, error) {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	album, ok := r.albumMap[id]
+	if !ok {
+		return nil, fmt.Errorf("album %d: %w", id, apperr.ErrNotFound)
+	}
+	if album.DeletedAt == nil {
+		return album, nil
+	}
+	restored := *album
+	restored.DeletedAt = nil
+	restored.UpdatedAt = r.now()
+	restored.Version++
+	r.put(&restored)
+	return &restored, nil
+}
+
+// before より前に論理削除したアルバムを完全に削除する
+func (r *albumRepository) Purge(ctx context.Context, before time.Time) ([]model.AlbumID, error) {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	ids := make([]model.AlbumID, 0)
+	for id, a := range r.albumMap {
+		if a.DeletedAt != nil && a.DeletedAt.Before(before) {
+			r.remove(id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// 更新・削除の対象のアルバムを取得し、バージョンを確認する (ロックは呼び出し側で取る)
+func (r *albumRepository) current(id model.AlbumID, version int) (*model.Album, error) {
+	album, ok := r.albumMap[id]
+	if !ok || album.DeletedAt != nil {
+		return nil, fmt.Errorf("album %d: %w", id, apperr.ErrNotFound)
+	}
+	if version != 0 && album.Version != version {
+		return nil, fmt.Errorf("album %d: %w", id, apperr.ErrPreconditionFailed)
+	}
+	return album, nil
+}
+
+// 指定した歌手が参加しているアルバムを取得する
+func (r *albumRepository) GetBySinger(ctx context.Context, singerID model.SingerID) ([]*model.Album, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return liveAlbums(r.albumsOf(singerID)), nil
+}
+
+// 指定した歌手がただ1人のメインの歌手であるアルバムを論理削除し、
+// それ以外のアルバムからは歌手の参加を外す
+func (r *albumRepository) DeleteBySinger(ctx context.Context, singerID model.SingerID) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	for _, a := range liveAlbums(r.albumsOf(singerID)) {
+		if soleArtist(a, singerID, model.ArtistRolePrimary) {
+			r.softDelete(a, now)
+		} else {
+			r.removeArtist(a, singerID, now)
+		}
+	}
+	return nil
+}
+
+// 指定した歌手の参加をすべてのアルバムから外す
+// 論理削除したアルバムも対象にし、完全に削除された歌手を参照しないようにする
+func (r *albumRepository) UnsetSinger(ctx context.Context, singerID model.SingerID) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	for _, a := range r.albumsOf(singerID) {
+		r.removeArtist(a, singerID, now)
+	}
 	return nil
 }
+
+// アルバムから歌手の参加を外す (ロックは呼び出し側で取る)
+// メインの歌手だった場合は次のメインの歌手を SingerID にする
+func (r *albumRepository) removeArtist(album *model.Album, singerID model.SingerID, now time.Time) {
+	// 取得済みのポインタに影響しないようにコピーを書き換える
+	updated := *album
+	updated.Artists = make([]model.AlbumArtist, 0, len(album.Artists))
+	for _, artist := range album.Artists {
+		if artist.SingerID != singerID {
+			updated.Artists = append(updated.Artists, artist)
+		}
+	}
+	updated.SingerID = 0
+	updated.SyncArtists()
+	updated.UpdatedAt = now
+	updated.Version++
+	r.put(&updated)
+}
+
+// 指定した役割の歌手が singerID の歌手だけか
+func soleArtist(album *model.Album, singerID model.SingerID, role model.ArtistRole) bool {
+	found := false
+	for _, artist := range album.Artists {
+		if artist.Role != role {
+			continue
+		}
+		if artist.SingerID != singerID {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// 登録されているアルバムの数を返す
+func (r *albumRepository) Count(ctx context.Context) (int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	n := 0
+	for _, a := range r.albumMap {
+		if a.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// 現在の状態を保存し、その状態に戻す関数を返す
+// 保存したアルバムは書き換えずにコピーを置き換えるので、マップだけをコピーし、インデックスは戻すときに作り直す
+func (r *albumRepository) snapshot() func() {
+	r.RLock()
+	defer r.RUnlock()
+
+	albums := make([]*model.Album, 0, len(r.albumMap))
+	for _, a := range r.albumMap {
+		albums = append(albums, a)
+	}
+	lastID := r.lastID
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		r.albumMap = map[model.AlbumID]*model.Album{}
+		r.singerIndex = map[model.SingerID]map[model.AlbumID]struct{}{}
+		for _, a := range albums {
+			r.put(a)
+		}
+		r.lastID = lastID
+	}
+}
+
+// fn を1つのトランザクションの中で実行する
+// インメモリDBのほかのリポジトリの操作も同じトランザクションになる
+func (r *albumRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, fn)
+}
@@ -0,0 +1,79 @@
+package memorydb
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// 歌手が検索条件に一致するか
+func matchSinger(s *model.Singer, f repository.SingerFilter) bool {
+	if s.DeletedAt != nil && !f.IncludeDeleted {
+		return false
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, s.ID) {
+		return false
+	}
+	if len(f.Names) > 0 && !slices.Contains(f.Names, repository.SingerName{Name: s.Name, Country: s.Country}) {
+		return false
+	}
+	if f.NameContains != "" && !nameContains(s, f.NameContains) {
+		return false
+	}
+	if !f.UpdatedSince.IsZero() && s.UpdatedAt.Before(f.UpdatedSince) {
+		return false
+	}
+	return true
+}
+
+// アルバムが検索条件に一致するか
+func matchAlbum(a *model.Album, f repository.AlbumFilter) bool {
+	if a.DeletedAt != nil && !f.IncludeDeleted {
+		return false
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, a.ID) {
+		return false
+	}
+	if f.SingerID != 0 && !a.HasArtist(f.SingerID) {
+		return false
+	}
+	if f.TitleContains != "" && !containsFold(a.Title, f.TitleContains) {
+		return false
+	}
+	if !f.UpdatedSince.IsZero() && a.UpdatedAt.Before(f.UpdatedSince) {
+		return false
+	}
+	if f.ReleasedAfter != "" && (a.ReleaseDate == "" || a.ReleaseDateStart() < f.ReleasedAfter) {
+		return false
+	}
+	if f.ReleasedBefore != "" && (a.ReleaseDate == "" || a.ReleaseDateStart() > f.ReleasedBefore) {
+		return false
+	}
+	if f.Genre != "" && !a.HasGenre(f.Genre) {
+		return false
+	}
+	if f.Format != "" && a.Format != f.Format {
+		return false
+	}
+	return true
+}
+
+// 歌手の名前か別名に substr を含むか
+func nameContains(s *model.Singer, substr string) bool {
+	if containsFold(s.Name, substr) {
+		return true
+	}
+	for _, alias := range s.Aliases {
+		if containsFold(alias, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 大文字小文字を区別しない部分一致
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,56 @@
+package memorydb
+
+import (
+	"sort"
+
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// 要素の並び替え上の位置 (並び替えキーの値とID)
+type position struct {
+	key string
+	id  int
+}
+
+// a が b より前にあるか (昇順の場合)
+func (a position) less(b position) bool {
+	if a.key != b.key {
+		return a.key < b.key
+	}
+	return a.id < b.id
+}
+
+// ListOptions に従って並び替え・カーソル・オフセット・件数制限を適用する
+// pos は要素の並び替え上の位置を返す関数
+func paginate[T any](items []T, opts repository.ListOptions, pos func(T) position) []T {
+	desc := opts.Desc()
+	sort.Slice(items, func(i, j int) bool {
+		if desc {
+			return pos(items[j]).less(pos(items[i]))
+		}
+		return pos(items[i]).less(pos(items[j]))
+	})
+
+	// カーソルより後ろの要素だけを残す
+	if c := opts.After; c != nil {
+		after := position{key: c.Key, id: c.ID}
+		start := sort.Search(len(items), func(i int) bool {
+			if desc {
+				return pos(items[i]).less(after)
+			}
+			return after.less(pos(items[i]))
+		})
+		items = items[start:]
+	}
+
+	if opts.Offset > 0 {
+		if opts.Offset >= len(items) {
+			return items[:0]
+		}
+		items = items[opts.Offset:]
+	}
+	if opts.Limit > 0 && opts.Limit < len(items) {
+		items = items[:opts.Limit]
+	}
+	return items
+}
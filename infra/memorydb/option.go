@@ -0,0 +1,30 @@
+package memorydb
+
+import "time"
+
+// リポジトリのオプション
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+}
+
+// 作成・更新・削除の日時に使う現在時刻の取得方法を指定する
+// テストで日時を固定するために使う
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// オプションを適用し、現在時刻を返す関数を作る
+// 保存する日時はバックエンドによらず UTC にそろえる
+func newClock(opts []Option) func() time.Time {
+	o := &options{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func() time.Time {
+		return o.now().UTC()
+	}
+}
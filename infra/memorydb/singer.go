@@ -2,9 +2,11 @@ package memorydb
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
+	"time"
 
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 	"github.com/pulse227/server-recruit-challenge-sample/repository"
 )
@@ -12,41 +14,44 @@ import (
 type singerRepository struct {
 	sync.RWMutex
 	singerMap map[model.SingerID]*model.Singer // キーが SingerID、値が model.Singer のマップ
+	lastID    model.SingerID                   // 最後に払い出したID (削除されても再利用しない)
+	now       func() time.Time                 // 作成・更新・削除の日時に使う現在時刻
 }
 
 var _ repository.SingerRepository = (*singerRepository)(nil)
-var singerRepo *singerRepository
-
-// 初期データを作成する関数
-func NewSingerRepository() *singerRepository {
-	// すでに初期データが作成されている場合はそれを返すs
-	if singerRepo == nil {
-		var initMap = map[model.SingerID]*model.Singer{
-			1: {ID: 1, Name: "Alice"},
-			2: {ID: 2, Name: "Bella"},
-			3: {ID: 3, Name: "Chris"},
-			4: {ID: 4, Name: "Daisy"},
-			5: {ID: 5, Name: "Ellen"},
-		}
-		return &singerRepository{
-			singerMap: initMap,
-		}
+var _ repository.Transactor = (*singerRepository)(nil)
+
+// 空のリポジトリを作成する
+// 初期データは seed パッケージで投入する
+func NewSingerRepository(opts ...Option) *singerRepository {
+	return &singerRepository{
+		singerMap: map[model.SingerID]*model.Singer{},
+		now:       newClock(opts),
 	}
-	return singerRepo
 }
 
-// すべての歌手を取得する
-func (r *singerRepository) GetAll(ctx context.Context) ([]*model.Singer, error) {
+// 歌手の一覧を取得する
+func (r *singerRepository) GetAll(ctx context.Context, opts repository.ListOptions) ([]*model.Singer, error) {
+	return r.Find(ctx, repository.SingerFilter{}, opts)
+}
+
+// 検索条件に一致する歌手を取得する
+func (r *singerRepository) Find(ctx context.Context, filter repository.SingerFilter, opts repository.ListOptions) ([]*model.Singer, error) {
 	// 書き込みの排他制御
 	r.RLock()
 	defer r.RUnlock()
 
-	// サイズが歌手人数のスライスを作成する
-	singers := make([]*model.Singer, 0, len(r.singerMap))
+	singers := make([]*model.Singer, 0)
 	for _, s := range r.singerMap {
-		singers = append(singers, s)
+		if matchSinger(s, filter) {
+			singers = append(singers, s)
+		}
 	}
-	return singers, nil
+	// マップの順序はランダムなので、並び替えてから返す
+	sortKey := opts.SortKey()
+	return paginate(singers, opts, func(s *model.Singer) position {
+		return position{key: repository.SingerSortValue(s, sortKey), id: int(s.ID)}
+	}), nil
 }
 
 // IDから歌手を取得する
@@ -56,26 +61,196 @@ func (r *singerRepository) Get(ctx context.Context, id model.SingerID) (*model.S
 
 	singer, ok := r.singerMap[id]
 	// インデックスが見つからない場合falseを返す
-	if !ok {
-		return nil, errors.New("not found")
+	if !ok || singer.DeletedAt != nil {
+		return nil, fmt.Errorf("singer %d: %w", id, apperr.ErrNotFound)
 	}
 	return singer, nil
 }
 
+// 複数のIDの歌手をまとめて取得する
+func (r *singerRepository) GetMany(ctx context.Context, ids []model.SingerID) ([]*model.Singer, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	singers := make([]*model.Singer, 0, len(ids))
+	for _, id := range ids {
+		if singer, ok := r.singerMap[id]; ok && singer.DeletedAt == nil {
+			singers = append(singers, singer)
+		}
+	}
+	return singers, nil
+}
+
 // 歌手を追加する
+// IDが0の場合は新しいIDを払い出し、singer.ID に設定する
 func (r *singerRepository) Add(ctx context.Context, singer *model.Singer) error {
 	// 書き込み時は排他制御を強く
+	defer beginWrite(ctx, r)()
 	r.Lock()
+	defer r.Unlock()
+
+	if err := r.checkUnique(singer); err != nil {
+		return err
+	}
+	if singer.ID == 0 {
+		r.lastID++
+		singer.ID = r.lastID
+	} else {
+		// 指定されたIDがすでに使われている場合は上書きしない
+		if _, ok := r.singerMap[singer.ID]; ok {
+			return fmt.Errorf("singer %d: %w", singer.ID, apperr.ErrConflict)
+		}
+		if singer.ID > r.lastID {
+			r.lastID = singer.ID
+		}
+	}
+	singer.Version = 1
+	singer.CreatedAt = r.now()
+	singer.UpdatedAt = singer.CreatedAt
+	singer.DeletedAt = nil
+	r.singerMap[singer.ID] = singer
+	return nil
+}
+
+// 歌手を更新する
+// singer.Version が 0 でない場合は現在のバージョンと一致するときだけ更新する
+func (r *singerRepository) Update(ctx context.Context, singer *model.Singer) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	current, err := r.current(singer.ID, singer.Version)
+	if err != nil {
+		return err
+	}
+	if err := r.checkUnique(singer); err != nil {
+		return err
+	}
+	singer.Version = current.Version + 1
+	singer.CreatedAt = current.CreatedAt
+	singer.UpdatedAt = r.now()
+	singer.DeletedAt = nil
 	r.singerMap[singer.ID] = singer
-	r.Unlock()
 	return nil
 }
 
-// 歌手を削除する
-func (r *singerRepository) Delete(ctx context.Context, id model.SingerID) error {
+// 歌手を論理削除する
+// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+func (r *singerRepository) Delete(ctx context.Context, id model.SingerID, version int) error {
 	// 削除時は排他制御を強く
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+	current, err := r.current(id, version)
+	if err != nil {
+		return err
+	}
+	// 取得済みのポインタに影響しないようにコピーを書き換える
+	deleted := *current
+	now := r.now()
+	deleted.DeletedAt = &now
+	deleted.UpdatedAt = now
+	deleted.Version++
+	r.singerMap[id] = &deleted
+	return nil
+}
+
+// 論理削除を取り消す
+func (r *singerRepository) Restore(ctx context.Context, id model.SingerID) (*model.Singer, error) {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	singer, ok := r.singerMap[id]
+	if !ok {
+		return nil, fmt.Errorf("singer %d: %w", id, apperr.ErrNotFound)
+	}
+	if singer.DeletedAt == nil {
+		return singer, nil
+	}
+	if err := r.checkUnique(singer); err != nil {
+		return nil, err
+	}
+	restored := *singer
+	restored.DeletedAt = nil
+	restored.UpdatedAt = r.now()
+	restored.Version++
+	r.singerMap[id] = &restored
+	return &restored, nil
+}
+
+// before より前に論理削除した歌手を完全に削除する
+func (r *singerRepository) Purge(ctx context.Context, before time.Time) ([]model.SingerID, error) {
+	defer beginWrite(ctx, r)()
 	r.Lock()
-	delete(r.singerMap, id)
-	r.Unlock()
+	defer r.Unlock()
+
+	ids := make([]model.SingerID, 0)
+	for id, s := range r.singerMap {
+		if s.DeletedAt != nil && s.DeletedAt.Before(before) {
+			delete(r.singerMap, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// 更新・削除の対象の歌手を取得し、バージョンを確認する (ロックは呼び出し側で取る)
+func (r *singerRepository) current(id model.SingerID, version int) (*model.Singer, error) {
+	singer, ok := r.singerMap[id]
+	if !ok || singer.DeletedAt != nil {
+		return nil, fmt.Errorf("singer %d: %w", id, apperr.ErrNotFound)
+	}
+	if version != 0 && singer.Version != version {
+		return nil, fmt.Errorf("singer %d: %w", id, apperr.ErrPreconditionFailed)
+	}
+	return singer, nil
+}
+
+// 同じ名前と国の歌手 (論理削除したものを除く) がほかにいる場合は Conflict を返す (ロックは呼び出し側で取る)
+func (r *singerRepository) checkUnique(singer *model.Singer) error {
+	for _, s := range r.singerMap {
+		if s.ID != singer.ID && s.DeletedAt == nil && s.Name == singer.Name && s.Country == singer.Country {
+			return fmt.Errorf("singer %q (country %q): %w", singer.Name, singer.Country, apperr.ErrConflict)
+		}
+	}
 	return nil
 }
+
+// 登録されている歌手の数を返す
+func (r *singerRepository) Count(ctx context.Context) (int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	n := 0
+	for _, s := range r.singerMap {
+		if s.DeletedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// 現在の状態を保存し、その状態に戻す関数を返す
+// 保存した歌手は書き換えずにコピーを置き換えるので、マップだけをコピーする
+func (r *singerRepository) snapshot() func() {
+	r.RLock()
+	defer r.RUnlock()
+
+	singerMap := make(map[model.SingerID]*model.Singer, len(r.singerMap))
+	for id, s := range r.singerMap {
+		singerMap[id] = s
+	}
+	lastID := r.lastID
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		r.singerMap, r.lastID = singerMap, lastID
+	}
+}
+
+// fn を1つのトランザクションの中で実行する
+// インメモリDBのほかのリポジトリの操作も同じトランザクションになる
+func (r *singerRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, fn)
+}
@@ -0,0 +1,189 @@
+package memorydb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+type trackRepository struct {
+	sync.RWMutex
+	// キーが AlbumID、値がトラック番号をキーにしたトラックのマップ
+	trackMap map[model.AlbumID]map[int]*model.Track
+	now      func() time.Time // 作成・更新の日時に使う現在時刻
+}
+
+var _ repository.TrackRepository = (*trackRepository)(nil)
+var _ repository.Transactor = (*trackRepository)(nil)
+
+// 空のリポジトリを作成する
+func NewTrackRepository(opts ...Option) *trackRepository {
+	return &trackRepository{
+		trackMap: map[model.AlbumID]map[int]*model.Track{},
+		now:      newClock(opts),
+	}
+}
+
+// 指定したアルバムのトラックをトラック番号順に並べる (ロックは呼び出し側で取る)
+func (r *trackRepository) tracksOf(albumID model.AlbumID) []*model.Track {
+	tracks := make([]*model.Track, 0, len(r.trackMap[albumID]))
+	for _, t := range r.trackMap[albumID] {
+		tracks = append(tracks, t)
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].Number < tracks[j].Number })
+	return tracks
+}
+
+// 指定したアルバムのトラックを取得する
+func (r *trackRepository) GetByAlbum(ctx context.Context, albumID model.AlbumID) ([]*model.Track, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.tracksOf(albumID), nil
+}
+
+// 複数のアルバムのトラックをまとめて取得する
+func (r *trackRepository) GetByAlbums(ctx context.Context, albumIDs []model.AlbumID) ([]*model.Track, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	tracks := make([]*model.Track, 0)
+	for _, id := range albumIDs {
+		tracks = append(tracks, r.tracksOf(id)...)
+	}
+	return tracks, nil
+}
+
+// 指定したトラックを取得する
+func (r *trackRepository) Get(ctx context.Context, albumID model.AlbumID, number int) (*model.Track, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	track, ok := r.trackMap[albumID][number]
+	if !ok {
+		return nil, fmt.Errorf("album %d track %d: %w", albumID, number, apperr.ErrNotFound)
+	}
+	return track, nil
+}
+
+// トラックを追加する
+// Number が 0 の場合はアルバムの最後のトラック番号の次を払い出す
+func (r *trackRepository) Add(ctx context.Context, track *model.Track) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	tracks, ok := r.trackMap[track.AlbumID]
+	if !ok {
+		tracks = map[int]*model.Track{}
+		r.trackMap[track.AlbumID] = tracks
+	}
+	if track.Number == 0 {
+		for n := range tracks {
+			if n > track.Number {
+				track.Number = n
+			}
+		}
+		track.Number++
+	} else if _, ok := tracks[track.Number]; ok {
+		// 指定された番号がすでに使われている場合は上書きしない
+		return fmt.Errorf("album %d track %d: %w", track.AlbumID, track.Number, apperr.ErrConflict)
+	}
+	track.Version = 1
+	track.CreatedAt = r.now()
+	track.UpdatedAt = track.CreatedAt
+	tracks[track.Number] = track
+	return nil
+}
+
+// トラックを更新する
+// track.Version が 0 でない場合は現在のバージョンと一致するときだけ更新する
+func (r *trackRepository) Update(ctx context.Context, track *model.Track) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	current, err := r.current(track.AlbumID, track.Number, track.Version)
+	if err != nil {
+		return err
+	}
+	track.Version = current.Version + 1
+	track.CreatedAt = current.CreatedAt
+	track.UpdatedAt = r.now()
+	r.trackMap[track.AlbumID][track.Number] = track
+	return nil
+}
+
+// トラックを削除する
+// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+func (r *trackRepository) Delete(ctx context.Context, albumID model.AlbumID, number int, version int) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	if _, err := r.current(albumID, number, version); err != nil {
+		return err
+	}
+	delete(r.trackMap[albumID], number)
+	if len(r.trackMap[albumID]) == 0 {
+		delete(r.trackMap, albumID)
+	}
+	return nil
+}
+
+// 更新・削除の対象のトラックを取得し、バージョンを確認する (ロックは呼び出し側で取る)
+func (r *trackRepository) current(albumID model.AlbumID, number int, version int) (*model.Track, error) {
+	track, ok := r.trackMap[albumID][number]
+	if !ok {
+		return nil, fmt.Errorf("album %d track %d: %w", albumID, number, apperr.ErrNotFound)
+	}
+	if version != 0 && track.Version != version {
+		return nil, fmt.Errorf("album %d track %d: %w", albumID, number, apperr.ErrPreconditionFailed)
+	}
+	return track, nil
+}
+
+// 指定したアルバムのトラックをすべて削除する
+func (r *trackRepository) DeleteByAlbums(ctx context.Context, albumIDs []model.AlbumID) error {
+	defer beginWrite(ctx, r)()
+	r.Lock()
+	defer r.Unlock()
+
+	for _, id := range albumIDs {
+		delete(r.trackMap, id)
+	}
+	return nil
+}
+
+// 現在の状態を保存し、その状態に戻す関数を返す
+// 保存したトラックは書き換えずに置き換えるので、アルバムごとのマップまでをコピーする
+func (r *trackRepository) snapshot() func() {
+	r.RLock()
+	defer r.RUnlock()
+
+	trackMap := make(map[model.AlbumID]map[int]*model.Track, len(r.trackMap))
+	for albumID, tracks := range r.trackMap {
+		copied := make(map[int]*model.Track, len(tracks))
+		for n, t := range tracks {
+			copied[n] = t
+		}
+		trackMap[albumID] = copied
+	}
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		r.trackMap = trackMap
+	}
+}
+
+// fn を1つのトランザクションの中で実行する
+// インメモリDBのほかのリポジトリの操作も同じトランザクションになる
+func (r *trackRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, fn)
+}
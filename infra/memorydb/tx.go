@@ -0,0 +1,70 @@
+package memorydb
+
+import (
+	"context"
+	"sync"
+)
+
+// インメモリDBのトランザクション
+// すべてのリポジトリの書き込みを1つのロックで順番に行い、WithinTx の fn の中の書き込みはロックを取ったまま行う
+// fn がエラーを返した場合は、fn の中で書き込んだリポジトリを fn を呼ぶ前の状態に戻す
+// (読み込みは排他しないため、ほかのリクエストから途中の状態が見えることがある)
+
+// すべてのリポジトリの書き込みを排他するロック
+var writeLock sync.Mutex
+
+type txKey struct{}
+
+// 実行中のトランザクション
+type tx struct {
+	saved     map[snapshotter]bool // 書き込む前の状態を保存したリポジトリ
+	rollbacks []func()             // 保存した状態に戻す関数 (保存した順)
+}
+
+// トランザクションで元に戻せるリポジトリ
+type snapshotter interface {
+	// 現在の状態を保存し、その状態に戻す関数を返す
+	snapshot() (restore func())
+}
+
+// fn を1つのトランザクションの中で実行する (repository.Transactor)
+// ctx がすでにトランザクションの中であれば、そのトランザクションに参加する
+func withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*tx); ok {
+		return fn(ctx)
+	}
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	t := &tx{saved: map[snapshotter]bool{}}
+	committed := false
+	// fn が panic した場合も元に戻す
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(t.rollbacks) - 1; i >= 0; i-- {
+			t.rollbacks[i]()
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txKey{}, t)); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// 書き込みを始める。戻り値の関数を書き込みが終わったときに呼ぶこと
+// トランザクションの外では書き込みが終わるまでロックを取り、
+// トランザクションの中ではリポジトリに最初に書き込む前に状態を保存する
+func beginWrite(ctx context.Context, repo snapshotter) (done func()) {
+	if t, ok := ctx.Value(txKey{}).(*tx); ok {
+		if !t.saved[repo] {
+			t.saved[repo] = true
+			t.rollbacks = append(t.rollbacks, repo.snapshot())
+		}
+		return func() {}
+	}
+	writeLock.Lock()
+	return writeLock.Unlock
+}
@@ -0,0 +1,412 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+type albumRepository struct {
+	db  *sql.DB
+	now func() time.Time // 作成・更新・削除の日時に使う現在時刻
+}
+
+var _ repository.AlbumRepository = (*albumRepository)(nil)
+var _ repository.Pinger = (*albumRepository)(nil)
+var _ repository.Transactor = (*albumRepository)(nil)
+
+// コンストラクタ
+func NewAlbumRepository(db *sql.DB, opts ...Option) *albumRepository {
+	return &albumRepository{db: db, now: newClock(opts)}
+}
+
+// アルバムを読み込むときのカラム (albumFields と同じ順)
+const albumColumns = `id, title, singer_id, release_date, genres, label, format, version, created_at, updated_at, deleted_at`
+
+// albumColumns の各カラムを読み込む先
+func albumFields(album *model.Album) []interface{} {
+	return []interface{}{
+		&album.ID, &album.Title, &album.SingerID, &album.ReleaseDate, stringList{&album.Genres}, &album.Label, &album.Format,
+		&album.Version, timeValue{&album.CreatedAt}, timeValue{&album.UpdatedAt}, nullTime{&album.DeletedAt},
+	}
+}
+
+// 並び替えキーとカラムの対応
+var albumSortColumns = map[string]string{
+	repository.SortByID:        "id",
+	repository.SortByTitle:     "title",
+	repository.SortByCreatedAt: "created_at",
+	repository.SortByUpdatedAt: "updated_at",
+}
+
+// アルバムの一覧を取得する
+func (r *albumRepository) GetAll(ctx context.Context, opts repository.ListOptions) ([]*model.Album, error) {
+	return r.Find(ctx, repository.AlbumFilter{}, opts)
+}
+
+// 検索条件に一致するアルバムを取得する
+func (r *albumRepository) Find(ctx context.Context, filter repository.AlbumFilter, opts repository.ListOptions) ([]*model.Album, error) {
+	q := &listQuery{}
+	if !filter.IncludeDeleted {
+		q.and("deleted_at IS NULL")
+	}
+	// IDはJSON配列として1つのパラメータで渡す
+	if len(filter.IDs) > 0 {
+		idsJSON, err := json.Marshal(filter.IDs)
+		if err != nil {
+			return nil, err
+		}
+		q.and("id IN (SELECT value FROM json_each(?))", string(idsJSON))
+	}
+	if filter.SingerID != 0 {
+		q.and("id IN (SELECT album_id FROM album_artists WHERE singer_id = ?)", filter.SingerID)
+	}
+	if filter.TitleContains != "" {
+		q.and("instr(lower(title), lower(?)) > 0", filter.TitleContains)
+	}
+	if !filter.UpdatedSince.IsZero() {
+		q.and("updated_at >= ?", formatTime(filter.UpdatedSince))
+	}
+	// 年や年月だけの発売日は期間の最初の日にして比べる (model.Album.ReleaseDateStart と同じ)
+	if filter.ReleasedAfter != "" {
+		q.and("release_date <> '' AND substr(release_date || '-01-01', 1, 10) >= ?", filter.ReleasedAfter)
+	}
+	if filter.ReleasedBefore != "" {
+		q.and("release_date <> '' AND substr(release_date || '-01-01', 1, 10) <= ?", filter.ReleasedBefore)
+	}
+	if filter.Genre != "" {
+		q.and("EXISTS (SELECT 1 FROM json_each(albums.genres) WHERE value = ?)", filter.Genre)
+	}
+	if filter.Format != "" {
+		q.and("format = ?", filter.Format)
+	}
+	query, args, err := q.build(`SELECT `+albumColumns+` FROM albums`, albumSortColumns, opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return loadAlbums(ctx, conn(ctx, r.db), rows)
+}
+
+// 指定したIDのアルバムを取得する
+func (r *albumRepository) Get(ctx context.Context, id model.AlbumID) (*model.Album, error) {
+	album := &model.Album{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+albumColumns+` FROM albums WHERE id = ? AND deleted_at IS NULL`, id).
+		Scan(albumFields(album)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("album %d: %w", id, apperr.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadArtists(ctx, conn(ctx, r.db), []*model.Album{album}); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// アルバムを追加する
+// IDが0の場合は新しいIDを払い出し、album.ID に設定する
+func (r *albumRepository) Add(ctx context.Context, album *model.Album) error {
+	album.SyncArtists()
+	now := r.now()
+	var id int64
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO albums (id, title, singer_id, release_date, genres, label, format, created_at, updated_at)
+			VALUES (NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			album.ID, album.Title, album.SingerID, album.ReleaseDate, formatStringList(album.Genres), album.Label, album.Format,
+			formatTime(now), formatTime(now))
+		if err != nil {
+			return err
+		}
+		// 指定されたIDがすでに使われている場合は上書きしない
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("album %d: %w", album.ID, apperr.ErrConflict)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		return saveArtists(ctx, tx, model.AlbumID(id), album.Artists)
+	})
+	if err != nil {
+		return err
+	}
+	album.ID = model.AlbumID(id)
+	album.Version = 1
+	album.CreatedAt = now
+	album.UpdatedAt = now
+	album.DeletedAt = nil
+	return nil
+}
+
+// アルバムを更新する
+// album.Version が 0 でない場合は現在のバージョンと一致するときだけ更新する
+func (r *albumRepository) Update(ctx context.Context, album *model.Album) error {
+	album.SyncArtists()
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`UPDATE albums SET title = ?, singer_id = ?, release_date = ?, genres = ?, label = ?, format = ?,
+				updated_at = ?, version = version + 1
+			WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+			RETURNING version, created_at, updated_at`,
+			album.Title, album.SingerID, album.ReleaseDate, formatStringList(album.Genres), album.Label, album.Format,
+			formatTime(r.now()), album.ID, album.Version, album.Version).
+			Scan(&album.Version, timeValue{&album.CreatedAt}, timeValue{&album.UpdatedAt})
+		if err != nil {
+			return err
+		}
+		return saveArtists(ctx, tx, album.ID, album.Artists)
+	})
+	// 理由の確認はトランザクションを終えてから行う
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.notAffected(ctx, album.ID)
+	}
+	return err
+}
+
+// アルバムを論理削除する
+// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+func (r *albumRepository) Delete(ctx context.Context, id model.AlbumID, version int) error {
+	now := formatTime(r.now())
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE albums SET deleted_at = ?, updated_at = ?, version = version + 1
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`,
+		now, now, id, version, version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.notAffected(ctx, id)
+	}
+	return nil
+}
+
+// 論理削除を取り消す
+func (r *albumRepository) Restore(ctx context.Context, id model.AlbumID) (*model.Album, error) {
+	if _, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE albums SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL`,
+		formatTime(r.now()), id); err != nil {
+		return nil, err
+	}
+	// 削除されていなかった場合もそのまま返す
+	return r.Get(ctx, id)
+}
+
+// before より前に論理削除したアルバムを完全に削除する
+func (r *albumRepository) Purge(ctx context.Context, before time.Time) ([]model.AlbumID, error) {
+	ids := make([]model.AlbumID, 0)
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`DELETE FROM albums WHERE deleted_at IS NOT NULL AND deleted_at < ? RETURNING id`, formatTime(before))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id model.AlbumID
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+		// 削除したアルバムの参加歌手も削除する
+		_, err = tx.ExecContext(ctx, `DELETE FROM album_artists WHERE album_id NOT IN (SELECT id FROM albums)`)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// 更新・削除の対象の行がなかった理由をエラーにする
+// アルバムが存在しない場合は NotFound、存在する場合はバージョンの不一致
+func (r *albumRepository) notAffected(ctx context.Context, id model.AlbumID) error {
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return fmt.Errorf("album %d: %w", id, apperr.ErrPreconditionFailed)
+}
+
+// 指定した歌手が参加しているアルバムを取得する
+func (r *albumRepository) GetBySinger(ctx context.Context, singerID model.SingerID) ([]*model.Album, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+albumColumns+` FROM albums
+		WHERE id IN (SELECT album_id FROM album_artists WHERE singer_id = ?) AND deleted_at IS NULL ORDER BY id`, singerID)
+	if err != nil {
+		return nil, err
+	}
+	return loadAlbums(ctx, conn(ctx, r.db), rows)
+}
+
+// 指定した歌手がただ1人のメインの歌手であるアルバムを論理削除し、
+// それ以外のアルバムからは歌手の参加を外す
+func (r *albumRepository) DeleteBySinger(ctx context.Context, singerID model.SingerID) error {
+	now := formatTime(r.now())
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		// 論理削除したアルバムは復元に備えて参加歌手を残す
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE albums SET deleted_at = ?, updated_at = ?, version = version + 1
+			WHERE deleted_at IS NULL
+				AND EXISTS (SELECT 1 FROM album_artists a WHERE a.album_id = albums.id AND a.role = 'primary' AND a.singer_id = ?)
+				AND NOT EXISTS (SELECT 1 FROM album_artists a WHERE a.album_id = albums.id AND a.role = 'primary' AND a.singer_id <> ?)`,
+			now, now, singerID, singerID); err != nil {
+			return err
+		}
+		return removeArtist(ctx, tx, singerID, now, true)
+	})
+}
+
+// 指定した歌手の参加をすべてのアルバムから外す
+// 論理削除したアルバムも対象にし、完全に削除された歌手を参照しないようにする
+func (r *albumRepository) UnsetSinger(ctx context.Context, singerID model.SingerID) error {
+	return withTx(ctx, r.db, func(tx *sql.Tx) error {
+		return removeArtist(ctx, tx, singerID, formatTime(r.now()), false)
+	})
+}
+
+// アルバムから歌手の参加を外す
+// liveOnly の場合は論理削除していないアルバムだけを対象にする
+// メインの歌手だった場合は次のメインの歌手を singer_id にする
+func removeArtist(ctx context.Context, tx *sql.Tx, singerID model.SingerID, now string, liveOnly bool) error {
+	live := ""
+	if liveOnly {
+		live = " AND deleted_at IS NULL"
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE albums SET updated_at = ?, version = version + 1
+		WHERE id IN (SELECT album_id FROM album_artists WHERE singer_id = ?)`+live,
+		now, singerID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM album_artists WHERE singer_id = ? AND album_id IN (SELECT id FROM albums WHERE true`+live+`)`,
+		singerID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`UPDATE albums SET singer_id = COALESCE((SELECT a.singer_id FROM album_artists a
+			WHERE a.album_id = albums.id AND a.role = 'primary' ORDER BY a.position LIMIT 1), 0)
+		WHERE singer_id = ?`+live,
+		singerID)
+	return err
+}
+
+// アルバムの参加歌手を置き換える
+func saveArtists(ctx context.Context, tx *sql.Tx, albumID model.AlbumID, artists []model.AlbumArtist) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM album_artists WHERE album_id = ?`, albumID); err != nil {
+		return err
+	}
+	for i, artist := range artists {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO album_artists (album_id, singer_id, role, position) VALUES (?, ?, ?, ?)`,
+			albumID, artist.SingerID, artist.Role, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// アルバムの参加歌手をまとめて読み込む
+func loadArtists(ctx context.Context, q queryer, albums []*model.Album) error {
+	if len(albums) == 0 {
+		return nil
+	}
+	byID := make(map[model.AlbumID]*model.Album, len(albums))
+	ids := make([]model.AlbumID, 0, len(albums))
+	for _, album := range albums {
+		album.Artists = []model.AlbumArtist{}
+		byID[album.ID] = album
+		ids = append(ids, album.ID)
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	rows, err := q.QueryContext(ctx, `SELECT album_id, singer_id, role FROM album_artists
+		WHERE album_id IN (SELECT value FROM json_each(?)) ORDER BY album_id, position`, string(idsJSON))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var albumID model.AlbumID
+		var artist model.AlbumArtist
+		if err := rows.Scan(&albumID, &artist.SingerID, &artist.Role); err != nil {
+			return err
+		}
+		album := byID[albumID]
+		album.Artists = append(album.Artists, artist)
+	}
+	return rows.Err()
+}
+
+// クエリ結果をアルバムのスライスに変換し、参加歌手も読み込む
+func loadAlbums(ctx context.Context, q queryer, rows *sql.Rows) ([]*model.Album, error) {
+	albums, err := scanAlbums(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadArtists(ctx, q, albums); err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+// クエリ結果をアルバムのスライスに変換する
+func scanAlbums(rows *sql.Rows) ([]*model.Album, error) {
+	defer rows.Close()
+
+	albums := make([]*model.Album, 0)
+	for rows.Next() {
+		album := &model.Album{}
+		if err := rows.Scan(albumFields(album)...); err != nil {
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return albums, nil
+}
+
+// 登録されているアルバムの数を返す
+func (r *albumRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM albums WHERE deleted_at IS NULL`).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// データベースに接続できるか確認する
+func (r *albumRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// fn を1つのトランザクションの中で実行する
+func (r *albumRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
+}
@@ -0,0 +1,86 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	// pure Go の SQLite ドライバ (cgo 不要)
+	_ "modernc.org/sqlite"
+)
+
+// ドライバ名
+const driverName = "sqlite"
+
+// データベースを開き、マイグレーションを適用する
+// dsn の例: "file:app.db" / ":memory:"
+func Open(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	// SQLite は書き込みが直列化されるため、コネクションは1本に制限する
+	// (":memory:" の場合はコネクションごとに別のDBになってしまうため必須)
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect database: %w", err)
+	}
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// クエリを実行できるもの (*sql.DB と *sql.Tx)
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// context で受け渡すトランザクション (WithinTx)
+type txKey struct{}
+
+type txValue struct {
+	db *sql.DB
+	tx *sql.Tx
+}
+
+// ctx に db のトランザクションがあればそれを、なければ db を返す
+// リポジトリのクエリはすべてこれを通し、WithinTx の中では同じトランザクションで実行する
+func conn(ctx context.Context, db *sql.DB) queryer {
+	if v, ok := ctx.Value(txKey{}).(txValue); ok && v.db == db {
+		return v.tx
+	}
+	return db
+}
+
+// トランザクションの中で fn を実行する
+// fn がエラーを返した場合はロールバックし、そのエラーを返す
+// コネクションは1本なので、fn の中では db ではなく tx を使うこと
+// ctx にすでに db のトランザクションがある場合はその中で実行し、確定・ロールバックは外側に任せる
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if v, ok := ctx.Value(txKey{}).(txValue); ok && v.db == db {
+		return fn(v.tx)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// fn を1つのトランザクションの中で実行する (repository.Transactor)
+// fn に渡す ctx を使えば、同じ db のほかのリポジトリの操作も同じトランザクションになる
+func withinTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, txValue{db: db, tx: tx}))
+	})
+}
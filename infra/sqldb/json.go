@@ -0,0 +1,88 @@
+package sqldb
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 文字列のリストをカラムに保存する JSON の配列に変換する
+// json_each で要素を検索できるように、空のリストも "[]" にする
+func formatStringList(list []string) string {
+	if len(list) == 0 {
+		return "[]"
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		// 文字列のスライスの変換は失敗しない
+		panic(err)
+	}
+	return string(b)
+}
+
+// JSON の配列のカラムを []string に読み込む
+// 空の配列は nil にする
+type stringList struct {
+	dst *[]string
+}
+
+func (l stringList) Scan(src interface{}) error {
+	b, err := jsonBytes(src)
+	if err != nil {
+		return err
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		list = nil
+	}
+	*l.dst = list
+	return nil
+}
+
+// 文字列のマップをカラムに保存する JSON のオブジェクトに変換する
+func formatStringMap(m map[string]string) string {
+	if len(m) == 0 {
+		return "{}"
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		// 文字列のマップの変換は失敗しない
+		panic(err)
+	}
+	return string(b)
+}
+
+// JSON のオブジェクトのカラムを map[string]string に読み込む
+// 空のオブジェクトは nil にする
+type stringMap struct {
+	dst *map[string]string
+}
+
+func (m stringMap) Scan(src interface{}) error {
+	b, err := jsonBytes(src)
+	if err != nil {
+		return err
+	}
+	var v map[string]string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if len(v) == 0 {
+		v = nil
+	}
+	*m.dst = v
+	return nil
+}
+
+// JSON のカラムの値をバイト列にする
+func jsonBytes(src interface{}) ([]byte, error) {
+	switch v := src.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported JSON value %T", src)
+}
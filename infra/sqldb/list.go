@@ -0,0 +1,63 @@
+package sqldb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// 一覧取得のクエリを組み立てる
+type listQuery struct {
+	where []string
+	args  []interface{}
+}
+
+// WHERE 条件を追加する
+func (q *listQuery) and(cond string, args ...interface{}) {
+	q.where = append(q.where, cond)
+	q.args = append(q.args, args...)
+}
+
+// ListOptions に従って並び替え・カーソル・件数制限を付けたクエリを返す
+// sortColumns は並び替えキーとカラム名の対応で、クエリに埋め込むのはこの値だけにする
+func (q *listQuery) build(selectFrom string, sortColumns map[string]string, opts repository.ListOptions) (string, []interface{}, error) {
+	column, ok := sortColumns[opts.SortKey()]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported sort key %q", opts.SortKey())
+	}
+	dir, cmp := "ASC", ">"
+	if opts.Desc() {
+		dir, cmp = "DESC", "<"
+	}
+
+	// カーソルより後ろの要素 (キーが同じ場合はIDで比較)
+	if c := opts.After; c != nil {
+		if column == "id" {
+			q.and("id "+cmp+" ?", c.ID)
+		} else {
+			q.and("("+column+", id) "+cmp+" (?, ?)", c.Key, c.ID)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(selectFrom)
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+	sb.WriteString(" ORDER BY ")
+	if column != "id" {
+		sb.WriteString(column + " " + dir + ", ")
+	}
+	sb.WriteString("id " + dir)
+
+	// SQLite では LIMIT -1 で上限なしになる
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	sb.WriteString(" LIMIT ? OFFSET ?")
+	args := append(q.args, limit, opts.Offset)
+	return sb.String(), args, nil
+}
@@ -0,0 +1,130 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// スキーマのマイグレーション
+// 適用済みのバージョンは schema_migrations テーブルに記録する
+// 新しいスキーマ変更は必ず末尾に追加し、既存の要素は書き換えないこと
+var migrations = []string{
+	// 1: 歌手・アルバムテーブルの作成
+	`CREATE TABLE singers (
+		id   INTEGER PRIMARY KEY,
+		name TEXT    NOT NULL
+	);
+	CREATE TABLE albums (
+		id        INTEGER PRIMARY KEY,
+		title     TEXT    NOT NULL,
+		singer_id INTEGER NOT NULL
+	);`,
+	// 2: IDを単調増加の連番にする (削除されたIDを再利用しない)
+	`CREATE TABLE singers_new (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT    NOT NULL
+	);
+	INSERT INTO singers_new (id, name) SELECT id, name FROM singers;
+	DROP TABLE singers;
+	ALTER TABLE singers_new RENAME TO singers;
+	CREATE TABLE albums_new (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		title     TEXT    NOT NULL,
+		singer_id INTEGER NOT NULL
+	);
+	INSERT INTO albums_new (id, title, singer_id) SELECT id, title, singer_id FROM albums;
+	DROP TABLE albums;
+	ALTER TABLE albums_new RENAME TO albums;`,
+	// 3: 歌手IDでアルバムを検索するためのインデックス
+	`CREATE INDEX albums_singer_id ON albums (singer_id);`,
+	// 4: 楽観的排他制御のためのバージョン
+	`ALTER TABLE singers ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE albums ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	// 5: 論理削除の日時 (NULL の場合は削除されていない)
+	`ALTER TABLE singers ADD COLUMN deleted_at TEXT;
+	ALTER TABLE albums ADD COLUMN deleted_at TEXT;`,
+	// 6: 作成・最終更新日時 (既存の行はマイグレーションの日時にする)
+	`ALTER TABLE singers ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+	ALTER TABLE singers ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+	UPDATE singers SET created_at = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now'), updated_at = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now');
+	ALTER TABLE albums ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+	ALTER TABLE albums ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+	UPDATE albums SET created_at = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now'), updated_at = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now');
+	CREATE INDEX singers_updated_at ON singers (updated_at);
+	CREATE INDEX albums_updated_at ON albums (updated_at);`,
+	// 7: アルバムのトラック (アルバムIDとトラック番号の組で識別する)
+	`CREATE TABLE tracks (
+		album_id         INTEGER NOT NULL,
+		number           INTEGER NOT NULL,
+		title            TEXT    NOT NULL,
+		duration_seconds INTEGER NOT NULL,
+		isrc             TEXT    NOT NULL DEFAULT '',
+		version          INTEGER NOT NULL DEFAULT 1,
+		created_at       TEXT    NOT NULL,
+		updated_at       TEXT    NOT NULL,
+		PRIMARY KEY (album_id, number)
+	);`,
+	// 8: アルバムに参加した歌手と役割 (albums.singer_id はメインの歌手として残す)
+	`CREATE TABLE album_artists (
+		album_id  INTEGER NOT NULL,
+		singer_id INTEGER NOT NULL,
+		role      TEXT    NOT NULL,
+		position  INTEGER NOT NULL,
+		PRIMARY KEY (album_id, singer_id, role)
+	);
+	CREATE INDEX album_artists_singer_id ON album_artists (singer_id);
+	INSERT INTO album_artists (album_id, singer_id, role, position)
+		SELECT id, singer_id, 'primary', 0 FROM albums WHERE singer_id <> 0;`,
+	// 9: アルバムの発売日・ジャンル (JSON の配列)・レーベル・形態
+	`ALTER TABLE albums ADD COLUMN release_date TEXT NOT NULL DEFAULT '';
+	ALTER TABLE albums ADD COLUMN genres TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE albums ADD COLUMN label TEXT NOT NULL DEFAULT '';
+	ALTER TABLE albums ADD COLUMN format TEXT NOT NULL DEFAULT '';
+	CREATE INDEX albums_release_date ON albums (release_date);`,
+	// 10: 歌手のプロフィールと別名 (JSON の配列)
+	// 名前と国の組の一意性はリポジトリで確認する (既存のデータに重複があってもマイグレーションできるように)
+	`ALTER TABLE singers ADD COLUMN stage_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE singers ADD COLUMN real_name TEXT NOT NULL DEFAULT '';
+	ALTER TABLE singers ADD COLUMN country TEXT NOT NULL DEFAULT '';
+	ALTER TABLE singers ADD COLUMN debut_year INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE singers ADD COLUMN external_ids TEXT NOT NULL DEFAULT '{}';
+	ALTER TABLE singers ADD COLUMN aliases TEXT NOT NULL DEFAULT '[]';
+	CREATE INDEX singers_name_country ON singers (name, country);`,
+}
+
+// 未適用のマイグレーションを順番に適用する
+func Migrate(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	// 現在のバージョンを取得
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		// 1つのマイグレーションは1トランザクションで適用する
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
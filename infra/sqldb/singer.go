@@ -0,0 +1,324 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+type singerRepository struct {
+	db  *sql.DB
+	now func() time.Time // 作成・更新・削除の日時に使う現在時刻
+}
+
+var _ repository.SingerRepository = (*singerRepository)(nil)
+var _ repository.Pinger = (*singerRepository)(nil)
+var _ repository.Transactor = (*singerRepository)(nil)
+
+// コンストラクタ
+func NewSingerRepository(db *sql.DB, opts ...Option) *singerRepository {
+	return &singerRepository{db: db, now: newClock(opts)}
+}
+
+// 歌手を読み込むときのカラム (singerFields と同じ順)
+const singerColumns = `id, name, stage_name, real_name, country, debut_year, external_ids, aliases, version, created_at, updated_at, deleted_at`
+
+// singerColumns の各カラムを読み込む先
+func singerFields(singer *model.Singer) []interface{} {
+	return []interface{}{
+		&singer.ID, &singer.Name, &singer.StageName, &singer.RealName, &singer.Country, &singer.DebutYear,
+		stringMap{&singer.ExternalIDs}, stringList{&singer.Aliases},
+		&singer.Version, timeValue{&singer.CreatedAt}, timeValue{&singer.UpdatedAt}, nullTime{&singer.DeletedAt},
+	}
+}
+
+// 並び替えキーとカラムの対応
+var singerSortColumns = map[string]string{
+	repository.SortByID:        "id",
+	repository.SortByName:      "name",
+	repository.SortByCreatedAt: "created_at",
+	repository.SortByUpdatedAt: "updated_at",
+}
+
+// 歌手の一覧を取得する
+func (r *singerRepository) GetAll(ctx context.Context, opts repository.ListOptions) ([]*model.Singer, error) {
+	return r.Find(ctx, repository.SingerFilter{}, opts)
+}
+
+// 検索条件に一致する歌手を取得する
+func (r *singerRepository) Find(ctx context.Context, filter repository.SingerFilter, opts repository.ListOptions) ([]*model.Singer, error) {
+	q := &listQuery{}
+	if !filter.IncludeDeleted {
+		q.and("deleted_at IS NULL")
+	}
+	// IDや名前と国の組はJSON配列として1つのパラメータで渡す
+	if len(filter.IDs) > 0 {
+		idsJSON, err := json.Marshal(filter.IDs)
+		if err != nil {
+			return nil, err
+		}
+		q.and("id IN (SELECT value FROM json_each(?))", string(idsJSON))
+	}
+	if len(filter.Names) > 0 {
+		pairs := make([][2]string, 0, len(filter.Names))
+		for _, n := range filter.Names {
+			pairs = append(pairs, [2]string{n.Name, n.Country})
+		}
+		namesJSON, err := json.Marshal(pairs)
+		if err != nil {
+			return nil, err
+		}
+		q.and(`EXISTS (SELECT 1 FROM json_each(?) p
+			WHERE json_extract(p.value, '$[0]') = singers.name AND json_extract(p.value, '$[1]') = singers.country)`,
+			string(namesJSON))
+	}
+	if filter.NameContains != "" {
+		q.and(`(instr(lower(name), lower(?)) > 0
+			OR EXISTS (SELECT 1 FROM json_each(singers.aliases) WHERE instr(lower(value), lower(?)) > 0))`,
+			filter.NameContains, filter.NameContains)
+	}
+	if !filter.UpdatedSince.IsZero() {
+		q.and("updated_at >= ?", formatTime(filter.UpdatedSince))
+	}
+	query, args, err := q.build(`SELECT `+singerColumns+` FROM singers`, singerSortColumns, opts)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanSingers(rows)
+}
+
+// IDから歌手を取得する
+func (r *singerRepository) Get(ctx context.Context, id model.SingerID) (*model.Singer, error) {
+	singer := &model.Singer{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+singerColumns+` FROM singers WHERE id = ? AND deleted_at IS NULL`, id).
+		Scan(singerFields(singer)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("singer %d: %w", id, apperr.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return singer, nil
+}
+
+// 複数のIDの歌手をまとめて取得する
+// IDの数によらず1回のクエリで取得するため、IDはJSON配列として1つのパラメータで渡す
+func (r *singerRepository) GetMany(ctx context.Context, ids []model.SingerID) ([]*model.Singer, error) {
+	if len(ids) == 0 {
+		return []*model.Singer{}, nil
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT `+singerColumns+` FROM singers WHERE id IN (SELECT value FROM json_each(?)) AND deleted_at IS NULL`, string(idsJSON))
+	if err != nil {
+		return nil, err
+	}
+	return scanSingers(rows)
+}
+
+// 歌手を追加する
+// IDが0の場合は新しいIDを払い出し、singer.ID に設定する
+func (r *singerRepository) Add(ctx context.Context, singer *model.Singer) error {
+	now := r.now()
+	var id int64
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if err := checkSingerUnique(ctx, tx, singer.ID, singer.Name, singer.Country); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO singers (id, name, stage_name, real_name, country, debut_year, external_ids, aliases, created_at, updated_at)
+			VALUES (NULLIF(?, 0), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			singer.ID, singer.Name, singer.StageName, singer.RealName, singer.Country, singer.DebutYear,
+			formatStringMap(singer.ExternalIDs), formatStringList(singer.Aliases), formatTime(now), formatTime(now))
+		if err != nil {
+			return err
+		}
+		// 指定されたIDがすでに使われている場合は上書きしない
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("singer %d: %w", singer.ID, apperr.ErrConflict)
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	singer.ID = model.SingerID(id)
+	singer.Version = 1
+	singer.CreatedAt = now
+	singer.UpdatedAt = now
+	singer.DeletedAt = nil
+	return nil
+}
+
+// 歌手を更新する
+// singer.Version が 0 でない場合は現在のバージョンと一致するときだけ更新する
+func (r *singerRepository) Update(ctx context.Context, singer *model.Singer) error {
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		if err := checkSingerUnique(ctx, tx, singer.ID, singer.Name, singer.Country); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx,
+			`UPDATE singers SET name = ?, stage_name = ?, real_name = ?, country = ?, debut_year = ?, external_ids = ?, aliases = ?,
+				updated_at = ?, version = version + 1
+			WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+			RETURNING version, created_at, updated_at`,
+			singer.Name, singer.StageName, singer.RealName, singer.Country, singer.DebutYear,
+			formatStringMap(singer.ExternalIDs), formatStringList(singer.Aliases),
+			formatTime(r.now()), singer.ID, singer.Version, singer.Version).
+			Scan(&singer.Version, timeValue{&singer.CreatedAt}, timeValue{&singer.UpdatedAt})
+	})
+	// 理由の確認はトランザクションを終えてから行う
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.notAffected(ctx, singer.ID)
+	}
+	return err
+}
+
+// 歌手を論理削除する
+// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+func (r *singerRepository) Delete(ctx context.Context, id model.SingerID, version int) error {
+	now := formatTime(r.now())
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE singers SET deleted_at = ?, updated_at = ?, version = version + 1
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`,
+		now, now, id, version, version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.notAffected(ctx, id)
+	}
+	return nil
+}
+
+// 論理削除を取り消す
+func (r *singerRepository) Restore(ctx context.Context, id model.SingerID) (*model.Singer, error) {
+	err := withTx(ctx, r.db, func(tx *sql.Tx) error {
+		var name, country string
+		err := tx.QueryRowContext(ctx, `SELECT name, country FROM singers WHERE id = ? AND deleted_at IS NOT NULL`, id).
+			Scan(&name, &country)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkSingerUnique(ctx, tx, id, name, country); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE singers SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ?`,
+			formatTime(r.now()), id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 削除されていなかった場合もそのまま返す
+	return r.Get(ctx, id)
+}
+
+// before より前に論理削除した歌手を完全に削除する
+func (r *singerRepository) Purge(ctx context.Context, before time.Time) ([]model.SingerID, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`DELETE FROM singers WHERE deleted_at IS NOT NULL AND deleted_at < ? RETURNING id`, formatTime(before))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]model.SingerID, 0)
+	for rows.Next() {
+		var id model.SingerID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// 更新・削除の対象の行がなかった理由をエラーにする
+// 歌手が存在しない場合は NotFound、存在する場合はバージョンの不一致
+func (r *singerRepository) notAffected(ctx context.Context, id model.SingerID) error {
+	if _, err := r.Get(ctx, id); err != nil {
+		return err
+	}
+	return fmt.Errorf("singer %d: %w", id, apperr.ErrPreconditionFailed)
+}
+
+// 同じ名前と国の歌手 (論理削除したものを除く) がほかにいる場合は Conflict を返す
+func checkSingerUnique(ctx context.Context, q queryer, id model.SingerID, name, country string) error {
+	var exists bool
+	if err := q.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM singers WHERE name = ? AND country = ? AND id <> ? AND deleted_at IS NULL)`,
+		name, country, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("singer %q (country %q): %w", name, country, apperr.ErrConflict)
+	}
+	return nil
+}
+
+// クエリ結果を歌手のスライスに変換する
+func scanSingers(rows *sql.Rows) ([]*model.Singer, error) {
+	defer rows.Close()
+
+	singers := make([]*model.Singer, 0)
+	for rows.Next() {
+		singer := &model.Singer{}
+		if err := rows.Scan(singerFields(singer)...); err != nil {
+			return nil, err
+		}
+		singers = append(singers, singer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return singers, nil
+}
+
+// 登録されている歌手の数を返す
+func (r *singerRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM singers WHERE deleted_at IS NULL`).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// データベースに接続できるか確認する
+func (r *singerRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// fn を1つのトランザクションの中で実行する
+func (r *singerRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
+}
@@ -0,0 +1,60 @@
+package sqldb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// 日時の保存形式
+// 並び替えのカーソルと同じ形式にして、カラムの値とカーソルのキーを文字列のまま比較できるようにする
+const timeLayout = repository.SortTimeLayout
+
+// 日時をカラムに保存する文字列に変換する
+func formatTime(t time.Time) string {
+	return repository.SortTimeValue(t)
+}
+
+// 日時のカラムを time.Time に読み込む
+type timeValue struct {
+	dst *time.Time
+}
+
+func (v timeValue) Scan(src interface{}) error {
+	var t *time.Time
+	if err := (nullTime{&t}).Scan(src); err != nil {
+		return err
+	}
+	if t == nil {
+		return fmt.Errorf("unexpected NULL time")
+	}
+	*v.dst = *t
+	return nil
+}
+
+// NULL を許す日時のカラムを *time.Time に読み込む
+type nullTime struct {
+	dst **time.Time
+}
+
+func (n nullTime) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*n.dst = nil
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("unsupported time value %T", src)
+	}
+	t, err := time.Parse(timeLayout, s)
+	if err != nil {
+		return err
+	}
+	*n.dst = &t
+	return nil
+}
@@ -0,0 +1,180 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+type trackRepository struct {
+	db  *sql.DB
+	now func() time.Time // 作成・更新の日時に使う現在時刻
+}
+
+var _ repository.TrackRepository = (*trackRepository)(nil)
+var _ repository.Pinger = (*trackRepository)(nil)
+var _ repository.Transactor = (*trackRepository)(nil)
+
+// コンストラクタ
+func NewTrackRepository(db *sql.DB, opts ...Option) *trackRepository {
+	return &trackRepository{db: db, now: newClock(opts)}
+}
+
+// 指定したアルバムのトラックを取得する
+func (r *trackRepository) GetByAlbum(ctx context.Context, albumID model.AlbumID) ([]*model.Track, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT album_id, number, title, duration_seconds, isrc, version, created_at, updated_at FROM tracks
+		WHERE album_id = ? ORDER BY number`, albumID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTracks(rows)
+}
+
+// 複数のアルバムのトラックをまとめて取得する
+func (r *trackRepository) GetByAlbums(ctx context.Context, albumIDs []model.AlbumID) ([]*model.Track, error) {
+	if len(albumIDs) == 0 {
+		return []*model.Track{}, nil
+	}
+	idsJSON, err := json.Marshal(albumIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT album_id, number, title, duration_seconds, isrc, version, created_at, updated_at FROM tracks
+		WHERE album_id IN (SELECT value FROM json_each(?)) ORDER BY album_id, number`, string(idsJSON))
+	if err != nil {
+		return nil, err
+	}
+	return scanTracks(rows)
+}
+
+// 指定したトラックを取得する
+func (r *trackRepository) Get(ctx context.Context, albumID model.AlbumID, number int) (*model.Track, error) {
+	track := &model.Track{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT album_id, number, title, duration_seconds, isrc, version, created_at, updated_at FROM tracks
+		WHERE album_id = ? AND number = ?`, albumID, number).
+		Scan(&track.AlbumID, &track.Number, &track.Title, &track.DurationSeconds, &track.ISRC, &track.Version, timeValue{&track.CreatedAt}, timeValue{&track.UpdatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("album %d track %d: %w", albumID, number, apperr.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// トラックを追加する
+// Number が 0 の場合はアルバムの最後のトラック番号の次を払い出す
+func (r *trackRepository) Add(ctx context.Context, track *model.Track) error {
+	now := r.now()
+	// 集約関数の結果は必ず1行になるので、トラックのないアルバムでも挿入できる
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO tracks (album_id, number, title, duration_seconds, isrc, created_at, updated_at)
+		SELECT ?, CASE WHEN ? = 0 THEN COALESCE(MAX(number), 0) + 1 ELSE ? END, ?, ?, ?, ?, ?
+		FROM tracks WHERE album_id = ?
+		ON CONFLICT (album_id, number) DO NOTHING
+		RETURNING number`,
+		track.AlbumID, track.Number, track.Number, track.Title, track.DurationSeconds, track.ISRC, formatTime(now), formatTime(now), track.AlbumID).
+		Scan(&track.Number)
+	// 指定された番号がすでに使われている場合は上書きしない
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("album %d track %d: %w", track.AlbumID, track.Number, apperr.ErrConflict)
+	}
+	if err != nil {
+		return err
+	}
+	track.Version = 1
+	track.CreatedAt = now
+	track.UpdatedAt = now
+	return nil
+}
+
+// トラックを更新する
+// track.Version が 0 でない場合は現在のバージョンと一致するときだけ更新する
+func (r *trackRepository) Update(ctx context.Context, track *model.Track) error {
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE tracks SET title = ?, duration_seconds = ?, isrc = ?, updated_at = ?, version = version + 1
+		WHERE album_id = ? AND number = ? AND (? = 0 OR version = ?)
+		RETURNING version, created_at, updated_at`,
+		track.Title, track.DurationSeconds, track.ISRC, formatTime(r.now()), track.AlbumID, track.Number, track.Version, track.Version).
+		Scan(&track.Version, timeValue{&track.CreatedAt}, timeValue{&track.UpdatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.notAffected(ctx, track.AlbumID, track.Number)
+	}
+	return err
+}
+
+// トラックを削除する
+// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+func (r *trackRepository) Delete(ctx context.Context, albumID model.AlbumID, number int, version int) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx,
+		`DELETE FROM tracks WHERE album_id = ? AND number = ? AND (? = 0 OR version = ?)`,
+		albumID, number, version, version)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return r.notAffected(ctx, albumID, number)
+	}
+	return nil
+}
+
+// 更新・削除の対象の行がなかった理由をエラーにする
+// トラックが存在しない場合は NotFound、存在する場合はバージョンの不一致
+func (r *trackRepository) notAffected(ctx context.Context, albumID model.AlbumID, number int) error {
+	if _, err := r.Get(ctx, albumID, number); err != nil {
+		return err
+	}
+	return fmt.Errorf("album %d track %d: %w", albumID, number, apperr.ErrPreconditionFailed)
+}
+
+// 指定したアルバムのトラックをすべて削除する
+func (r *trackRepository) DeleteByAlbums(ctx context.Context, albumIDs []model.AlbumID) error {
+	if len(albumIDs) == 0 {
+		return nil
+	}
+	idsJSON, err := json.Marshal(albumIDs)
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, r.db).ExecContext(ctx, `DELETE FROM tracks WHERE album_id IN (SELECT value FROM json_each(?))`, string(idsJSON))
+	return err
+}
+
+// クエリ結果をトラックのスライスに変換する
+func scanTracks(rows *sql.Rows) ([]*model.Track, error) {
+	defer rows.Close()
+
+	tracks := make([]*model.Track, 0)
+	for rows.Next() {
+		track := &model.Track{}
+		if err := rows.Scan(&track.AlbumID, &track.Number, &track.Title, &track.DurationSeconds, &track.ISRC, &track.Version, timeValue{&track.CreatedAt}, timeValue{&track.UpdatedAt}); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tracks, nil
+}
+
+// データベースに接続できるか確認する
+func (r *trackRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// fn を1つのトランザクションの中で実行する
+func (r *trackRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.db, fn)
+}
@@ -3,42 +3,131 @@ package main
 // インポート
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/pulse227/server-recruit-challenge-sample/api"
+	"github.com/pulse227/server-recruit-challenge-sample/config"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"github.com/pulse227/server-recruit-challenge-sample/seed"
+	"github.com/pulse227/server-recruit-challenge-sample/service"
 )
 
 func main() {
-	// interruptシグナルを受信したときに、コンテキストにキャンセルを通知する
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	// 設定の読み込み (フラグ・環境変数・設定ファイル)
+	// 不正な設定の場合は起動しない
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// ロガーの設定
+	level, _ := cfg.Level()
+	logger, _ := logging.New(os.Stderr, cfg.LogFormat, level)
+	slog.SetDefault(logger)
+
+	// interruptシグナル・SIGTERM (コンテナの停止時に送られる) を受信したときに、コンテキストにキャンセルを通知する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// リポジトリの作成
+	var singerRepo repository.SingerRepository
+	var albumRepo repository.AlbumRepository
+	var trackRepo repository.TrackRepository
+	switch cfg.Storage.Backend {
+	case config.BackendSQLite:
+		db, err := sqldb.Open(ctx, cfg.Storage.DSN)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer db.Close()
+		singerRepo = sqldb.NewSingerRepository(db)
+		albumRepo = sqldb.NewAlbumRepository(db)
+		trackRepo = sqldb.NewTrackRepository(db)
+	default:
+		singerRepo = memorydb.NewSingerRepository()
+		albumRepo = memorydb.NewAlbumRepository()
+		trackRepo = memorydb.NewTrackRepository()
+	}
+
+	// 初期データの投入
+	// ファイルの指定がない場合、インメモリDBにはサンプルデータを投入する
+	var data *seed.Data
+	if cfg.SeedFile != "" {
+		if data, err = seed.Load(cfg.SeedFile); err != nil {
+			log.Fatal(err)
+		}
+	} else if cfg.Storage.Backend == config.BackendMemory {
+		data = seed.Sample()
+	}
+	if data != nil {
+		if err := seed.Apply(ctx, singerRepo, albumRepo, data); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// Routerの作成
-	r := api.NewRouter()
+	policy, _ := service.ParseSingerDeletePolicy(cfg.SingerDeletePolicy)
+	readiness := &api.Readiness{}
+	r := api.NewRouter(
+		api.WithRepositories(singerRepo, albumRepo),
+		api.WithTrackRepository(trackRepo),
+		api.WithSingerDeletePolicy(policy),
+		api.WithReadiness(readiness),
+		api.WithMaxBodyBytes(cfg.MaxBodyBytes),
+		api.WithAdminToken(cfg.Admin.Token),
+		api.WithAdminMaxBodyBytes(cfg.Admin.MaxBodyBytes),
+		api.WithAdminTimeout(cfg.Admin.Timeout),
+	)
+
+	// 保存期間を過ぎた論理削除データの完全削除
+	if cfg.Purge.Interval > 0 {
+		go service.RunPurgeJob(ctx, service.NewPurgeService(singerRepo, albumRepo, trackRepo), cfg.Purge.Interval, cfg.Purge.Retention)
+	}
 
 	// HTTPサーバーの作成
 	server := &http.Server{
-		Addr:    ":8888",
-		Handler: r,
+		Addr:         cfg.Addr,
+		Handler:      r,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
 	}
 
 	// ゴルーチンの作成
 	// Graceful Shutdown
+	// 処理中のリクエストが終わるまで main を終了しないよう、完了を idle で通知する
+	idle := make(chan struct{})
 	go func() {
+		defer close(idle)
 		// コンテキストのキャンセル通知を待機
 		<-ctx.Done()
+		// 先に /readyz を失敗させ、ロードバランサーが振り分けを止めるまで待つ
+		readiness.ShuttingDown()
+		log.Printf("shutting down (waiting %s for load balancers to drain)", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
 		// タイムアウト用のコンテキスト作成
-		// 5s待機してからシャットダウンを行う
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		// 設定した時間だけ処理中のリクエストを待ってからシャットダウンを行う
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 		defer cancel()
 		// サーバーシャットダウン
 		server.Shutdown(ctx)
 	}()
-	log.Println("server start running at :8888")
+	log.Printf("server start running at %s (storage: %s)", cfg.Addr, cfg.Storage.Backend)
 	// サーバーの起動
-	log.Fatal(server.ListenAndServe())
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-idle
+	log.Println("server stopped")
 }
@@ -1,11 +1,126 @@
 package model
 
+import "time"
+
 // アルバムスキーマの定義
 
 type AlbumID int
 
 type Album struct {
-	ID       AlbumID  `json:"id"`
-	Title    string   `json:"title"`
-	SingerID SingerID `json:"singer_id"` // モデル Singer の ID と紐づきます
+	ID    AlbumID `json:"id"`
+	Title string  `json:"title"`
+	// メインの歌手 (Artists の最初の primary の歌手, モデル Singer の ID と紐づきます)
+	// 歌手が1人だった頃のクライアントのために残している
+	SingerID SingerID `json:"singer_id"`
+	// アルバムに参加した歌手と役割 (並び順はクレジットの順)
+	Artists []AlbumArtist `json:"artists"`
+	// 発売日 (ISO 8601 の YYYY-MM-DD。日や月が分からない場合は YYYY-MM や YYYY)
+	ReleaseDate string `json:"release_date,omitempty"`
+	// ジャンル (小文字に揃える)
+	Genres []string `json:"genres,omitempty"`
+	// レーベル
+	Label string `json:"label,omitempty"`
+	// 形態 (CD, レコード, 配信)
+	Format AlbumFormat `json:"format,omitempty"`
+	// 更新のたびに1ずつ増えるバージョン (ETag に使う)
+	Version int `json:"-"`
+	// 作成・最終更新日時 (リポジトリが設定する)
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// 論理削除した日時 (削除されていない場合は nil)
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// アルバムの形態
+type AlbumFormat string
+
+const (
+	AlbumFormatCD      AlbumFormat = "cd"
+	AlbumFormatVinyl   AlbumFormat = "vinyl"
+	AlbumFormatDigital AlbumFormat = "digital"
+)
+
+// 形態として有効な値か
+func (f AlbumFormat) Valid() bool {
+	switch f {
+	case AlbumFormatCD, AlbumFormatVinyl, AlbumFormatDigital:
+		return true
+	}
+	return false
+}
+
+// アルバムへの歌手の関わり方
+type ArtistRole string
+
+const (
+	ArtistRolePrimary  ArtistRole = "primary"  // メインの歌手
+	ArtistRoleFeatured ArtistRole = "featured" // 客演
+	ArtistRoleProducer ArtistRole = "producer" // プロデューサー
+)
+
+// 役割として有効な値か
+func (r ArtistRole) Valid() bool {
+	switch r {
+	case ArtistRolePrimary, ArtistRoleFeatured, ArtistRoleProducer:
+		return true
+	}
+	return false
+}
+
+// アルバムに参加した歌手
+type AlbumArtist struct {
+	SingerID SingerID   `json:"singer_id"`
+	Role     ArtistRole `json:"role"`
+}
+
+// SingerID と Artists を揃える
+// Artists が空の場合は SingerID の歌手をメインの歌手とし、
+// それ以外は Artists の最初のメインの歌手を SingerID にする (いない場合は 0)
+func (a *Album) SyncArtists() {
+	if len(a.Artists) == 0 {
+		a.Artists = []AlbumArtist{}
+		if a.SingerID != 0 {
+			a.Artists = append(a.Artists, AlbumArtist{SingerID: a.SingerID, Role: ArtistRolePrimary})
+		}
+		return
+	}
+	a.SingerID = 0
+	for _, artist := range a.Artists {
+		if artist.Role == ArtistRolePrimary {
+			a.SingerID = artist.SingerID
+			return
+		}
+	}
+}
+
+// 指定した歌手が参加しているか
+func (a *Album) HasArtist(singerID SingerID) bool {
+	for _, artist := range a.Artists {
+		if artist.SingerID == singerID {
+			return true
+		}
+	}
+	return false
+}
+
+// 発売日をその期間の最初の日 (YYYY-MM-DD) にして返す (発売日がない場合は空文字列)
+// 年や年月だけの発売日を日付と比べるときに使う
+func (a *Album) ReleaseDateStart() string {
+	switch len(a.ReleaseDate) {
+	case len("2006"):
+		return a.ReleaseDate + "-01-01"
+	case len("2006-01"):
+		return a.ReleaseDate + "-01"
+	}
+	return a.ReleaseDate
+}
+
+// 指定したジャンルを含むか
+func (a *Album) HasGenre(genre string) bool {
+	for _, g := range a.Genres {
+		if g == genre {
+			return true
+		}
+	}
+	return false
 }
@@ -1,9 +1,33 @@
 package model
 
+import "time"
+
 // アルバムスキーマの定義
 
 type AlbumSinger struct {
 	ID     AlbumID `json:"id"`
 	Title  string  `json:"title"`
-	Singer Singer  `json:"singer"`
+	Singer *Singer `json:"singer"` // メインの歌手 (歌手の紐づけがない場合は null)
+	// アルバムに参加したすべての歌手と役割 (削除済みの歌手は含めない)
+	Singers []*CreditedSinger `json:"singers"`
+	// 発売日・ジャンル・レーベル・形態 (model.Album と同じ)
+	ReleaseDate string      `json:"release_date,omitempty"`
+	Genres      []string    `json:"genres,omitempty"`
+	Label       string      `json:"label,omitempty"`
+	Format      AlbumFormat `json:"format,omitempty"`
+	// トラック (?include=tracks を指定した場合だけ含める。トラックがない場合も省略する)
+	Tracks []*Track `json:"tracks,omitempty"`
+	// アルバムのバージョン
+	Version int `json:"-"`
+	// アルバムの作成・最終更新日時
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// アルバムを論理削除した日時 (削除されていない場合は nil)
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// アルバムに参加した歌手 (歌手の項目に役割を加えたもの)
+type CreditedSinger struct {
+	*Singer
+	Role ArtistRole `json:"role"`
 }
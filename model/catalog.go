@@ -0,0 +1,8 @@
+package model
+
+// カタログの一括取り込み・書き出し (/admin/import, /admin/export) の1件
+// 歌手かアルバムのどちらか一方だけを持つ
+type CatalogRecord struct {
+	Singer *Singer `json:"singer,omitempty"`
+	Album  *Album  `json:"album,omitempty"`
+}
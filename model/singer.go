@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // 歌手スキーマの定義
 
 type SingerID int
@@ -8,4 +10,22 @@ type SingerID int
 type Singer struct {
 	ID   SingerID `json:"id"`
 	Name string   `json:"name"`
+	// 芸名と本名 (分かる場合だけ設定する)
+	StageName string `json:"stage_name,omitempty"`
+	RealName  string `json:"real_name,omitempty"`
+	// 出身国 (ISO 3166-1 alpha-2 の大文字2文字。Name と組み合わせて一意になる)
+	Country string `json:"country,omitempty"`
+	// デビューした年
+	DebutYear int `json:"debut_year,omitempty"`
+	// 外部サービスでのID (キーはサービス名。例: "musicbrainz")
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+	// 別名 (名前の検索の対象にもなる)
+	Aliases []string `json:"aliases,omitempty"`
+	// 更新のたびに1ずつ増えるバージョン (ETag に使う)
+	Version int `json:"-"`
+	// 作成・最終更新日時 (リポジトリが設定する)
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// 論理削除した日時 (削除されていない場合は nil)
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
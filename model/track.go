@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// トラックスキーマの定義
+// トラックはアルバムに属し、アルバムIDとトラック番号の組で識別する
+
+type Track struct {
+	AlbumID AlbumID `json:"album_id"` // モデル Album の ID と紐づきます
+	Number  int     `json:"number"`   // アルバム内のトラック番号 (1から)
+	Title   string  `json:"title"`
+	// 再生時間 (秒)
+	DurationSeconds int `json:"duration_seconds"`
+	// 国際標準レコーディングコード (例: JPAB01234567, 未登録の場合は空)
+	ISRC string `json:"isrc,omitempty"`
+	// 更新のたびに1ずつ増えるバージョン (ETag に使う)
+	Version int `json:"-"`
+	// 作成・最終更新日時 (リポジトリが設定する)
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
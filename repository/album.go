@@ -2,14 +2,39 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 )
 
 // Album用のCRUDのインターフェース
 type AlbumRepository interface {
-	GetAll(ctx context.Context) ([]*model.Album, error)
+	GetAll(ctx context.Context, opts ListOptions) ([]*model.Album, error)
+	// 検索条件に一致するアルバムを取得する
+	Find(ctx context.Context, filter AlbumFilter, opts ListOptions) ([]*model.Album, error)
+	// 論理削除したアルバムは NotFound になる
 	Get(ctx context.Context, id model.AlbumID) (*model.Album, error)
+	// SingerID と Artists は model.Album.SyncArtists で揃えてから保存する (Update も同じ)
 	Add(ctx context.Context, Album *model.Album) error
-	Delete(ctx context.Context, id model.AlbumID) error
+	// Version が 0 でない場合は現在のバージョンと一致するときだけ更新し、一致しない場合は apperr.ErrPreconditionFailed を返す
+	// 更新後のバージョンを Album.Version に設定する
+	Update(ctx context.Context, Album *model.Album) error
+	// 論理削除する (deleted_at を設定し、通常の取得・検索の対象から外す)
+	// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+	Delete(ctx context.Context, id model.AlbumID, version int) error
+	// 論理削除を取り消す (削除されていない場合は何もしない)
+	Restore(ctx context.Context, id model.AlbumID) (*model.Album, error)
+	// before より前に論理削除したアルバムを完全に削除し、削除したアルバムのIDを返す
+	Purge(ctx context.Context, before time.Time) ([]model.AlbumID, error)
+	// 登録されているアルバムの数を返す (論理削除したものは含めない)
+	Count(ctx context.Context) (int, error)
+
+	// 指定した歌手が (役割によらず) 参加しているアルバムを取得する
+	GetBySinger(ctx context.Context, singerID model.SingerID) ([]*model.Album, error)
+	// 指定した歌手がただ1人のメインの歌手であるアルバムを論理削除し、
+	// それ以外のアルバムからは歌手の参加を外す
+	DeleteBySinger(ctx context.Context, singerID model.SingerID) error
+	// 指定した歌手の参加をすべてのアルバムから外す
+	// メインの歌手だった場合は SingerID を次のメインの歌手 (いない場合は 0) にする
+	UnsetSinger(ctx context.Context, singerID model.SingerID) error
 }
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// 歌手の検索条件
+// ゼロ値の項目は条件に含めない
+type SingerFilter struct {
+	IDs            []model.SingerID // いずれかのIDの歌手だけを含める
+	Names          []SingerName     // 名前と国がいずれかの組に一致する歌手だけを含める (別名は含めない)
+	NameContains   string           // 名前か別名の部分一致 (大文字小文字を区別しない。SQLite では ASCII のみ)
+	IncludeDeleted bool             // 論理削除した歌手も含める
+	UpdatedSince   time.Time        // 指定した日時以降に更新された歌手だけを含める
+}
+
+// 歌手の名前と国の組 (論理削除していない歌手の間で一意)
+type SingerName struct {
+	Name    string
+	Country string
+}
+
+// アルバムの検索条件
+// ゼロ値の項目は条件に含めない
+type AlbumFilter struct {
+	IDs            []model.AlbumID // いずれかのIDのアルバムだけを含める
+	SingerID       model.SingerID  // 歌手ID (役割によらず歌手が参加しているアルバム)
+	TitleContains  string          // タイトルの部分一致 (大文字小文字を区別しない。SQLite では ASCII のみ)
+	IncludeDeleted bool            // 論理削除したアルバムも含める
+	UpdatedSince   time.Time       // 指定した日時以降に更新されたアルバムだけを含める
+	// 発売日がこの日 (YYYY-MM-DD) 以降・以前のアルバムだけを含める (発売日のないアルバムは含めない)
+	// 年や年月だけの発売日はその期間の最初の日として比べる
+	ReleasedAfter  string
+	ReleasedBefore string
+	Genre          string            // ジャンル (完全一致。保存されている値と同じく小文字で指定する)
+	Format         model.AlbumFormat // 形態
+}
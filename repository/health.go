@@ -0,0 +1,9 @@
+package repository
+
+import "context"
+
+// 接続先の状態を確認できるリポジトリが実装するインターフェース
+// 実装していないリポジトリ (インメモリDBなど) は常に利用可能とみなす
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
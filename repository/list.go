@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// 並び順
+type SortOrder string
+
+const (
+	OrderAsc  SortOrder = "asc"
+	OrderDesc SortOrder = "desc"
+)
+
+// 並び替えのキー
+const (
+	SortByID        = "id"
+	SortByName      = "name"
+	SortByTitle     = "title"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+)
+
+// 並び替えに使用できるキー
+var (
+	SingerSortKeys = []string{SortByID, SortByName, SortByCreatedAt, SortByUpdatedAt}
+	AlbumSortKeys  = []string{SortByID, SortByTitle, SortByCreatedAt, SortByUpdatedAt}
+)
+
+// 日時の並び替えキーの形式
+// 固定長の UTC の文字列にして、文字列の比較で前後関係が分かるようにする
+const SortTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// 日時を並び替えキーの値に変換する
+func SortTimeValue(t time.Time) string {
+	return t.UTC().Format(SortTimeLayout)
+}
+
+// 一覧取得のオプション
+// 並び替えキーが同じ要素は ID 順に並べるため、順序は常に一意に決まる
+type ListOptions struct {
+	Sort   string    // 並び替えのキー (空の場合は "id")
+	Order  SortOrder // 並び順 (空の場合は昇順)
+	Limit  int       // 取得件数の上限 (0 の場合は上限なし)
+	Offset int       // 先頭から読み飛ばす件数
+	After  *Cursor   // 指定した場合はカーソルより後ろの要素だけを取得する
+}
+
+// 並び替えキーの既定値を補ったキーを返す
+func (o ListOptions) SortKey() string {
+	if o.Sort == "" {
+		return SortByID
+	}
+	return o.Sort
+}
+
+// 降順かどうか
+func (o ListOptions) Desc() bool {
+	return o.Order == OrderDesc
+}
+
+// ページングのカーソル
+// 直前のページの最後の要素の並び替えキーの値とIDを保持する
+type Cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k,omitempty"`
+	ID   int    `json:"i"`
+}
+
+// クライアントに返す文字列に変換する
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// クライアントから受け取った文字列をカーソルに変換する
+func ParseCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+	return c, nil
+}
+
+// 歌手の並び替えキーの値
+// "id" の場合は ID だけで並べるので空文字列を返す
+func SingerSortValue(s *model.Singer, sort string) string {
+	switch sort {
+	case SortByName:
+		return s.Name
+	case SortByCreatedAt:
+		return SortTimeValue(s.CreatedAt)
+	case SortByUpdatedAt:
+		return SortTimeValue(s.UpdatedAt)
+	}
+	return ""
+}
+
+// アルバムの並び替えキーの値
+func AlbumSortValue(a *model.Album, sort string) string {
+	switch sort {
+	case SortByTitle:
+		return a.Title
+	case SortByCreatedAt:
+		return SortTimeValue(a.CreatedAt)
+	case SortByUpdatedAt:
+		return SortTimeValue(a.UpdatedAt)
+	}
+	return ""
+}
+
+// 歌手のカーソルを作成する
+func SingerCursor(s *model.Singer, sort string) *Cursor {
+	return &Cursor{Sort: sort, Key: SingerSortValue(s, sort), ID: int(s.ID)}
+}
+
+// アルバムのカーソルを作成する
+func AlbumCursor(a *model.Album, sort string) *Cursor {
+	return &Cursor{Sort: sort, Key: AlbumSortValue(a, sort), ID: int(a.ID)}
+}
@@ -2,13 +2,32 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 )
 
+// 名前と国の組は論理削除していない歌手の間で一意にする
+// Add, Update, Restore で同じ名前と国の歌手がほかにいる場合は apperr.ErrConflict を返す
 type SingerRepository interface {
-	GetAll(ctx context.Context) ([]*model.Singer, error)
+	GetAll(ctx context.Context, opts ListOptions) ([]*model.Singer, error)
+	// 検索条件に一致する歌手を取得する
+	Find(ctx context.Context, filter SingerFilter, opts ListOptions) ([]*model.Singer, error)
+	// 論理削除した歌手は NotFound になる
 	Get(ctx context.Context, id model.SingerID) (*model.Singer, error)
+	// 複数のIDの歌手をまとめて取得する (存在しないIDは結果に含めない)
+	GetMany(ctx context.Context, ids []model.SingerID) ([]*model.Singer, error)
 	Add(ctx context.Context, singer *model.Singer) error
-	Delete(ctx context.Context, id model.SingerID) error
+	// Version が 0 でない場合は現在のバージョンと一致するときだけ更新し、一致しない場合は apperr.ErrPreconditionFailed を返す
+	// 更新後のバージョンを singer.Version に設定する
+	Update(ctx context.Context, singer *model.Singer) error
+	// 論理削除する (deleted_at を設定し、通常の取得・検索の対象から外す)
+	// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+	Delete(ctx context.Context, id model.SingerID, version int) error
+	// 論理削除を取り消す (削除されていない場合は何もしない)
+	Restore(ctx context.Context, id model.SingerID) (*model.Singer, error)
+	// before より前に論理削除した歌手を完全に削除し、削除した歌手のIDを返す
+	Purge(ctx context.Context, before time.Time) ([]model.SingerID, error)
+	// 登録されている歌手の数を返す (論理削除したものは含めない)
+	Count(ctx context.Context) (int, error)
 }
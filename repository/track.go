@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+)
+
+// Track用のCRUDのインターフェース
+// アルバムの存在は確認しないので、呼び出し側 (サービス) で確認すること
+type TrackRepository interface {
+	// 指定したアルバムのトラックをトラック番号順に取得する
+	GetByAlbum(ctx context.Context, albumID model.AlbumID) ([]*model.Track, error)
+	// 複数のアルバムのトラックをまとめて取得する (トラック番号順)
+	// トラックのないアルバムは結果に含まれない
+	GetByAlbums(ctx context.Context, albumIDs []model.AlbumID) ([]*model.Track, error)
+	Get(ctx context.Context, albumID model.AlbumID, number int) (*model.Track, error)
+	// Number が 0 の場合はアルバムの最後のトラック番号の次を払い出し、track.Number に設定する
+	// 指定した番号がすでに使われている場合は apperr.ErrConflict を返す
+	Add(ctx context.Context, track *model.Track) error
+	// Version が 0 でない場合は現在のバージョンと一致するときだけ更新し、一致しない場合は apperr.ErrPreconditionFailed を返す
+	// 更新後のバージョンを Track.Version に設定する
+	Update(ctx context.Context, track *model.Track) error
+	// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+	Delete(ctx context.Context, albumID model.AlbumID, number int, version int) error
+	// 指定したアルバムのトラックをすべて削除する (アルバムの完全削除に合わせて呼ぶ)
+	DeleteByAlbums(ctx context.Context, albumIDs []model.AlbumID) error
+}
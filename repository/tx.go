@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// 複数のリポジトリの操作を1つのトランザクションで行えるリポジトリが実装するインターフェース
+// 実装していないリポジトリでは、操作はそれぞれ個別に反映される
+type Transactor interface {
+	// fn に渡す ctx で呼び出した (同じデータベースの) リポジトリの操作を1つのトランザクションで行う
+	// fn がエラーを返した場合はすべて取り消す
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
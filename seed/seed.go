@@ -0,0 +1,103 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// 起動時に投入する初期データ
+type Data struct {
+	Singers []*model.Singer `json:"singers"`
+	Albums  []*model.Album  `json:"albums"`
+}
+
+// サンプルの初期データ
+func Sample() *Data {
+	return &Data{
+		Singers: []*model.Singer{
+			{ID: 1, Name: "Alice"},
+			{ID: 2, Name: "Bella"},
+			{ID: 3, Name: "Chris"},
+			{ID: 4, Name: "Daisy"},
+			{ID: 5, Name: "Ellen"},
+		},
+		Albums: []*model.Album{
+			{ID: 1, Title: "Alice's 1st Album", SingerID: 1},
+			{ID: 2, Title: "Alice's 2nd Album", SingerID: 1},
+			{ID: 3, Title: "Bella's 1st Album", SingerID: 2},
+		},
+	}
+}
+
+// YAML または JSON のファイルから初期データを読み込む
+// キー名は API の JSON と同じ (singers[].id, albums[].singer_id など)
+func Load(path string) (*Data, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("seed: %w", err)
+	}
+	// JSON は YAML として読めるので、YAML として読んでから JSON のタグで詰め直す
+	var raw interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("seed %s: %w", path, err)
+	}
+	j, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("seed %s: %w", path, err)
+	}
+	data := &Data{}
+	if err := json.Unmarshal(j, data); err != nil {
+		return nil, fmt.Errorf("seed %s: %w", path, err)
+	}
+	if err := data.validate(); err != nil {
+		return nil, fmt.Errorf("seed %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// 再起動のたびに同じデータを投入しても重複しないように、IDの指定を必須にする
+func (d *Data) validate() error {
+	var errs []error
+	for i, s := range d.Singers {
+		if s == nil || s.ID <= 0 {
+			errs = append(errs, fmt.Errorf("singers[%d]: id is required", i))
+		} else if s.Name == "" {
+			errs = append(errs, fmt.Errorf("singers[%d]: name is required", i))
+		}
+	}
+	for i, a := range d.Albums {
+		if a == nil || a.ID <= 0 {
+			errs = append(errs, fmt.Errorf("albums[%d]: id is required", i))
+		} else if a.Title == "" {
+			errs = append(errs, fmt.Errorf("albums[%d]: title is required", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// 初期データをリポジトリに投入する
+// すでに同じIDのデータがある場合は上書きせずに読み飛ばす
+func Apply(ctx context.Context, singerRepo repository.SingerRepository, albumRepo repository.AlbumRepository, data *Data) error {
+	for _, s := range data.Singers {
+		// 呼び出し側のデータを書き換えないようにコピーを渡す
+		singer := *s
+		if err := singerRepo.Add(ctx, &singer); err != nil && !errors.Is(err, apperr.ErrConflict) {
+			return fmt.Errorf("seed singer %d: %w", s.ID, err)
+		}
+	}
+	for _, a := range data.Albums {
+		album := *a
+		if err := albumRepo.Add(ctx, &album); err != nil && !errors.Is(err, apperr.ErrConflict) {
+			return fmt.Errorf("seed album %d: %w", a.ID, err)
+		}
+	}
+	return nil
+}
@@ -2,37 +2,61 @@ package service
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 	"github.com/pulse227/server-recruit-challenge-sample/repository"
 )
 
 type AlbumService interface {
-	GetAlbumListService(ctx context.Context) ([]*model.Album, error)
+	GetAlbumListService(ctx context.Context, filter repository.AlbumFilter, opts repository.ListOptions) ([]*model.Album, *repository.Cursor, error)
 	GetAlbumService(ctx context.Context, AlbumID model.AlbumID) (*model.Album, error)
 	PostAlbumService(ctx context.Context, Album *model.Album) error
-	DeleteAlbumService(ctx context.Context, AlbumID model.AlbumID) error
+	PutAlbumService(ctx context.Context, Album *model.Album) error
+	PatchAlbumService(ctx context.Context, AlbumID model.AlbumID, apply func(*model.Album) error) (*model.Album, error)
+	// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+	DeleteAlbumService(ctx context.Context, AlbumID model.AlbumID, version int) error
+	// 論理削除したアルバムを元に戻す
+	RestoreAlbumService(ctx context.Context, AlbumID model.AlbumID) (*model.Album, error)
 }
 
 type albumService struct {
-	albumRepository repository.AlbumRepository
+	albumRepository  repository.AlbumRepository
+	singerRepository repository.SingerRepository
 }
 
 // albumServiceがAlbumServiceを実装
 var _ AlbumService = (*albumService)(nil)
 
 // コンストラクタ
-func NewAlbumService(albumRepository repository.AlbumRepository) *albumService {
-	return &albumService{albumRepository: albumRepository}
+func NewAlbumService(albumRepository repository.AlbumRepository, singerRepository repository.SingerRepository) *albumService {
+	return &albumService{
+		albumRepository:  albumRepository,
+		singerRepository: singerRepository,
+	}
 }
 
 // GetAlbumListService
-func (s *albumService) GetAlbumListService(ctx context.Context) ([]*model.Album, error) {
-	albums, err := s.albumRepository.GetAll(ctx)
+// 検索条件に一致するアルバムの一覧を取得する
+// 続きのページがある場合は次のページのカーソルも返す
+func (s *albumService) GetAlbumListService(ctx context.Context, filter repository.AlbumFilter, opts repository.ListOptions) ([]*model.Album, *repository.Cursor, error) {
+	// 続きがあるかを判定するため1件多く取得する
+	fetch := opts
+	if opts.Limit > 0 {
+		fetch.Limit = opts.Limit + 1
+	}
+	albums, err := s.albumRepository.Find(ctx, filter, fetch)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	var next *repository.Cursor
+	if opts.Limit > 0 && len(albums) > opts.Limit {
+		albums = albums[:opts.Limit]
+		next = repository.AlbumCursor(albums[len(albums)-1], opts.SortKey())
 	}
-	return albums, nil
+	return albums, next, nil
 }
 
 // GetAlbumService
@@ -46,21 +70,112 @@ func (s *albumService) GetAlbumService(ctx context.Context, AlbumID model.AlbumI
 
 // PostAlbumService
 func (s *albumService) PostAlbumService(ctx context.Context, Album *model.Album) error {
-	if err := s.albumRepository.Add(ctx, Album); err != nil {
-		return err
+	// 存在しない歌手を参照するアルバムは登録しない
+	// (確認と登録の間に歌手が削除されないよう、1つのトランザクションで行う)
+	return withinTx(ctx, s.albumRepository, func(ctx context.Context) error {
+		if err := s.checkArtistsExist(ctx, Album, nil); err != nil {
+			return err
+		}
+		return s.albumRepository.Add(ctx, Album)
+	})
+}
+
+// PutAlbumService
+func (s *albumService) PutAlbumService(ctx context.Context, Album *model.Album) error {
+	return withinTx(ctx, s.albumRepository, func(ctx context.Context) error {
+		// 存在チェック
+		if _, err := s.albumRepository.Get(ctx, Album.ID); err != nil {
+			return err
+		}
+		// 存在しない歌手を参照するアルバムには更新しない
+		if err := s.checkArtistsExist(ctx, Album, nil); err != nil {
+			return err
+		}
+		return s.albumRepository.Update(ctx, Album)
+	})
+}
+
+// PatchAlbumService
+// apply には現在のアルバムのコピーが渡されるので、変更したい項目だけを書き換える
+// 取得してから更新するまでにほかの更新があった場合は apperr.ErrPreconditionFailed を返す
+func (s *albumService) PatchAlbumService(ctx context.Context, AlbumID model.AlbumID, apply func(*model.Album) error) (*model.Album, error) {
+	current, err := s.albumRepository.Get(ctx, AlbumID)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	album := *current
+	if err := apply(&album); err != nil {
+		return nil, err
+	}
+	// IDは変更させない
+	album.ID = AlbumID
+	// 取得したときのバージョンのままの場合だけ更新し、その間のほかの更新を上書きしない
+	album.Version = current.Version
+
+	// 新しく参加する歌手だけを確認する
+	// (参加したあとに削除された歌手が残っていても、他の項目は更新できるようにする)
+	err = withinTx(ctx, s.albumRepository, func(ctx context.Context) error {
+		if err := s.checkArtistsExist(ctx, &album, current); err != nil {
+			return err
+		}
+		return s.albumRepository.Update(ctx, &album)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &album, nil
 }
 
 // DeleteAlbumService
-func (s *albumService) DeleteAlbumService(ctx context.Context, AlbumID model.AlbumID) error {
+func (s *albumService) DeleteAlbumService(ctx context.Context, AlbumID model.AlbumID, version int) error {
 	// 存在チェック
 	if _, err := s.albumRepository.Get(ctx, AlbumID); err != nil {
 		return err
 	}
 	// 削除
-	if err := s.albumRepository.Delete(ctx, AlbumID); err != nil {
+	if err := s.albumRepository.Delete(ctx, AlbumID, version); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RestoreAlbumService
+// 歌手が論理削除されている場合も戻し、歌手を戻すと再び紐づく
+func (s *albumService) RestoreAlbumService(ctx context.Context, AlbumID model.AlbumID) (*model.Album, error) {
+	return s.albumRepository.Restore(ctx, AlbumID)
+}
+
+// アルバムに参加する歌手が存在するかを確認する
+// current (更新前のアルバム) を指定した場合は、すでに参加している歌手の確認を省く
+// 存在しない場合は入力値のエラーとして扱う
+func (s *albumService) checkArtistsExist(ctx context.Context, album *model.Album, current *model.Album) error {
+	album.SyncArtists()
+	ids := make([]model.SingerID, 0, len(album.Artists))
+	seen := make(map[model.SingerID]bool, len(album.Artists))
+	for _, artist := range album.Artists {
+		if seen[artist.SingerID] || (current != nil && current.HasArtist(artist.SingerID)) {
+			continue
+		}
+		seen[artist.SingerID] = true
+		ids = append(ids, artist.SingerID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	// まとめて取得する (歌手ごとに問い合わせない)
+	singers, err := s.singerRepository.GetMany(ctx, ids)
+	if err != nil {
 		return err
 	}
+	found := make(map[model.SingerID]bool, len(singers))
+	for _, singer := range singers {
+		found[singer.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return fmt.Errorf("singer %d does not exist: %w", id, apperr.ErrValidation)
+		}
+	}
 	return nil
 }
@@ -3,56 +3,66 @@ package service
 import (
 	"context"
 
+	"github.com/pulse227/server-recruit-challenge-sample/logging"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
 )
 
 type AlbumSingerService interface {
-	GetAlbumSingerListService(ctx context.Context) ([]*model.AlbumSinger, error)
+	GetAlbumSingerListService(ctx context.Context, filter repository.AlbumFilter, opts repository.ListOptions) ([]*model.AlbumSinger, *repository.Cursor, error)
 	GetAlbumSingerService(ctx context.Context, AlbumID model.AlbumID) (*model.AlbumSinger, error)
 	PostAlbumSingerService(ctx context.Context, Album *model.Album) error
-	DeleteAlbumSingerService(ctx context.Context, AlbumID model.AlbumID) error
+	PutAlbumSingerService(ctx context.Context, Album *model.Album) error
+	PatchAlbumSingerService(ctx context.Context, AlbumID model.AlbumID, apply func(*model.Album) error) (*model.Album, error)
+	DeleteAlbumSingerService(ctx context.Context, AlbumID model.AlbumID, version int) error
+	RestoreAlbumSingerService(ctx context.Context, AlbumID model.AlbumID) (*model.Album, error)
+
+	// 歌手ごとのアルバム (/singers/{id}/albums)
+	GetSingerAlbumListService(ctx context.Context, singerID model.SingerID, opts repository.ListOptions) ([]*model.Album, *repository.Cursor, error)
+	PostSingerAlbumService(ctx context.Context, singerID model.SingerID, Album *model.Album) error
+
+	// アルバムにトラックを結合する (?include=tracks)
+	AttachTracksService(ctx context.Context, albums []*model.AlbumSinger) error
 }
 
 type albumSingerService struct {
 	albumSvc  albumService
 	singerSvc singerService
+	trackSvc  trackService
 }
 
 var _ AlbumSingerService = (*albumSingerService)(nil)
 
-func NewAlbumSingerService(albumSvc *albumService, singerSvc *singerService) *albumSingerService {
+func NewAlbumSingerService(albumSvc *albumService, singerSvc *singerService, trackSvc *trackService) *albumSingerService {
 	return &albumSingerService{
 		albumSvc:  *albumSvc,
 		singerSvc: *singerSvc,
+		trackSvc:  *trackSvc,
 	}
 }
 
-func (s *albumSingerService) GetAlbumSingerListService(ctx context.Context) ([]*model.AlbumSinger, error) {
+func (s *albumSingerService) GetAlbumSingerListService(ctx context.Context, filter repository.AlbumFilter, opts repository.ListOptions) ([]*model.AlbumSinger, *repository.Cursor, error) {
 	// アルバムデータの取得
-	albums, err := s.albumSvc.GetAlbumListService(ctx)
+	albums, next, err := s.albumSvc.GetAlbumListService(ctx, filter, opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// 歌手データをまとめて取得 (アルバムごとに問い合わせない)
+	singers, err := s.getArtistSingers(ctx, albums...)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// レスポンスデータの初期化
 	albumsSinger := make([]*model.AlbumSinger, 0, len(albums))
 
-	// 歌手データの取得
 	for _, album := range albums {
-		singer, err := s.singerSvc.GetSingerService(ctx, album.SingerID)
-		if err != nil {
-			return nil, err
-		}
-
 		// アルバムと歌手のデータを結合
-		albumsSinger = append(albumsSinger, &model.AlbumSinger{
-			ID:     album.ID,
-			Title:  album.Title,
-			Singer: *singer,
-		})
+		albumsSinger = append(albumsSinger, joinSingers(ctx, album, singers))
 	}
 
-	return albumsSinger, nil
+	return albumsSinger, next, nil
 }
 
 func (s *albumSingerService) GetAlbumSingerService(ctx context.Context, AlbumID model.AlbumID) (*model.AlbumSinger, error) {
@@ -63,18 +73,13 @@ func (s *albumSingerService) GetAlbumSingerService(ctx context.Context, AlbumID
 	}
 
 	// 歌手データの取得
-	singer, err := s.singerSvc.GetSingerService(ctx, album.SingerID)
+	singers, err := s.getArtistSingers(ctx, album)
 	if err != nil {
 		return nil, err
 	}
 
 	// アルバムと歌手のデータを結合
-	return &model.AlbumSinger{
-		ID:     album.ID,
-		Title:  album.Title,
-		Singer: *singer,
-	}, nil
-
+	return joinSingers(ctx, album, singers), nil
 }
 
 func (s *albumSingerService) PostAlbumSingerService(ctx context.Context, Album *model.Album) error {
@@ -85,10 +90,111 @@ func (s *albumSingerService) PostAlbumSingerService(ctx context.Context, Album *
 	return nil
 }
 
-func (s *albumSingerService) DeleteAlbumSingerService(ctx context.Context, AlbumID model.AlbumID) error {
+func (s *albumSingerService) PutAlbumSingerService(ctx context.Context, Album *model.Album) error {
+	// アルバムデータの更新
+	if err := s.albumSvc.PutAlbumService(ctx, Album); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *albumSingerService) PatchAlbumSingerService(ctx context.Context, AlbumID model.AlbumID, apply func(*model.Album) error) (*model.Album, error) {
+	// アルバムデータの部分更新
+	return s.albumSvc.PatchAlbumService(ctx, AlbumID, apply)
+}
+
+func (s *albumSingerService) DeleteAlbumSingerService(ctx context.Context, AlbumID model.AlbumID, version int) error {
 	// アルバムデータの削除
-	if err := s.albumSvc.DeleteAlbumService(ctx, AlbumID); err != nil {
+	if err := s.albumSvc.DeleteAlbumService(ctx, AlbumID, version); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *albumSingerService) RestoreAlbumSingerService(ctx context.Context, AlbumID model.AlbumID) (*model.Album, error) {
+	// アルバムデータの復元
+	return s.albumSvc.RestoreAlbumService(ctx, AlbumID)
+}
+
+// 指定した歌手のアルバムの一覧を取得する
+// 歌手が存在しない場合は NotFound を返す
+func (s *albumSingerService) GetSingerAlbumListService(ctx context.Context, singerID model.SingerID, opts repository.ListOptions) ([]*model.Album, *repository.Cursor, error) {
+	// 歌手の存在チェック
+	if _, err := s.singerSvc.GetSingerService(ctx, singerID); err != nil {
+		return nil, nil, err
+	}
+	return s.albumSvc.GetAlbumListService(ctx, repository.AlbumFilter{SingerID: singerID}, opts)
+}
+
+// 指定した歌手のアルバムを登録する
+// 歌手が存在しない場合は NotFound を返す
+func (s *albumSingerService) PostSingerAlbumService(ctx context.Context, singerID model.SingerID, Album *model.Album) error {
+	// 歌手の存在チェック
+	if _, err := s.singerSvc.GetSingerService(ctx, singerID); err != nil {
+		return err
+	}
+	Album.SingerID = singerID
+	return s.albumSvc.PostAlbumService(ctx, Album)
+}
+
+// アルバムにトラックを結合する
+// トラックはまとめて取得する (アルバムごとに問い合わせない)
+func (s *albumSingerService) AttachTracksService(ctx context.Context, albums []*model.AlbumSinger) error {
+	albumIDs := make([]model.AlbumID, 0, len(albums))
+	for _, album := range albums {
+		albumIDs = append(albumIDs, album.ID)
+	}
+	tracks, err := s.trackSvc.GetTrackMapService(ctx, albumIDs)
+	if err != nil {
 		return err
 	}
+	for _, album := range albums {
+		album.Tracks = tracks[album.ID]
+	}
 	return nil
 }
+
+// アルバムに参加している歌手をまとめて取得し、IDをキーにしたマップで返す
+func (s *albumSingerService) getArtistSingers(ctx context.Context, albums ...*model.Album) (map[model.SingerID]*model.Singer, error) {
+	// 参照されている歌手のID (重複なし)
+	singerIDs := make([]model.SingerID, 0, len(albums))
+	seen := make(map[model.SingerID]bool, len(albums))
+	for _, album := range albums {
+		for _, artist := range album.Artists {
+			if !seen[artist.SingerID] {
+				seen[artist.SingerID] = true
+				singerIDs = append(singerIDs, artist.SingerID)
+			}
+		}
+	}
+	return s.singerSvc.GetSingerMapService(ctx, singerIDs)
+}
+
+// アルバムと歌手のデータを結合する
+// 削除済みの歌手を参照している場合はその歌手を含めず (メインの歌手なら歌手なし (nil) として扱い)、
+// 一覧全体がエラーにならないようにする
+func joinSingers(ctx context.Context, album *model.Album, singers map[model.SingerID]*model.Singer) *model.AlbumSinger {
+	credits := make([]*model.CreditedSinger, 0, len(album.Artists))
+	for _, artist := range album.Artists {
+		singer, ok := singers[artist.SingerID]
+		if !ok {
+			logging.FromContext(ctx).Warn("album references missing singer", "album_id", album.ID, "singer_id", artist.SingerID)
+			continue
+		}
+		credits = append(credits, &model.CreditedSinger{Singer: singer, Role: artist.Role})
+	}
+	return &model.AlbumSinger{
+		ID:          album.ID,
+		Title:       album.Title,
+		Singer:      singers[album.SingerID],
+		Singers:     credits,
+		ReleaseDate: album.ReleaseDate,
+		Genres:      album.Genres,
+		Label:       album.Label,
+		Format:      album.Format,
+		Version:     album.Version,
+		CreatedAt:   album.CreatedAt,
+		UpdatedAt:   album.UpdatedAt,
+		DeletedAt:   album.DeletedAt,
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// 問い合わせ回数を数える SingerRepository
+type countingSingerRepository struct {
+	repository.SingerRepository
+	queries int
+}
+
+func (r *countingSingerRepository) Get(ctx context.Context, id model.SingerID) (*model.Singer, error) {
+	r.queries++
+	return r.SingerRepository.Get(ctx, id)
+}
+
+func (r *countingSingerRepository) GetMany(ctx context.Context, ids []model.SingerID) ([]*model.Singer, error) {
+	r.queries++
+	return r.SingerRepository.GetMany(ctx, ids)
+}
+
+// SQLite に歌手とアルバムを登録したサービスを作成する
+func newBenchAlbumSingerService(b *testing.B, singers, albums int) (*albumSingerService, *countingSingerRepository) {
+	b.Helper()
+	ctx := context.Background()
+	db, err := sqldb.Open(ctx, ":memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	singerRepo := &countingSingerRepository{SingerRepository: sqldb.NewSingerRepository(db)}
+	albumRepo := sqldb.NewAlbumRepository(db)
+	for i := 0; i < singers; i++ {
+		if err := singerRepo.Add(ctx, &model.Singer{Name: fmt.Sprintf("Singer %d", i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for i := 0; i < albums; i++ {
+		album := &model.Album{Title: fmt.Sprintf("Album %d", i), SingerID: model.SingerID(i%singers + 1)}
+		if err := albumRepo.Add(ctx, album); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	singerSvc := NewSingerService(singerRepo, albumRepo, SingerDeleteRestrict)
+	albumSvc := NewAlbumService(albumRepo, singerRepo)
+	trackSvc := NewTrackService(sqldb.NewTrackRepository(db), albumRepo)
+	return NewAlbumSingerService(albumSvc, singerSvc, trackSvc), singerRepo
+}
+
+// アルバム一覧に歌手を結合する処理のベンチマーク
+// PerAlbum はアルバムごとに歌手を取得する (N+1) 従来の方法、Batch は GetMany でまとめて取得する方法
+func BenchmarkAlbumSingerList(b *testing.B) {
+	const singers, albums = 1000, 5000
+	ctx := context.Background()
+	all := repository.ListOptions{}
+
+	b.Run("PerAlbum", func(b *testing.B) {
+		svc, counter := newBenchAlbumSingerService(b, singers, albums)
+		counter.queries = 0
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			list, _, err := svc.albumSvc.GetAlbumListService(ctx, repository.AlbumFilter{}, all)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, album := range list {
+				if _, err := svc.singerSvc.GetSingerService(ctx, album.SingerID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(counter.queries)/float64(b.N), "singer-queries/op")
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		svc, counter := newBenchAlbumSingerService(b, singers, albums)
+		counter.queries = 0
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			list, _, err := svc.GetAlbumSingerListService(ctx, repository.AlbumFilter{}, all)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(list) != albums {
+				b.Fatalf("got %d albums", len(list))
+			}
+		}
+		b.ReportMetric(float64(counter.queries)/float64(b.N), "singer-queries/op")
+	})
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// カタログ (歌手とアルバム) の一括取り込みと書き出し (/admin/import, /admin/export)
+type CatalogService interface {
+	// すべての件を確認してから、歌手、アルバムの順に登録する
+	// 問題がある場合は何も登録せずに ImportErrors を返す。dryRun の場合は確認だけ行う
+	// 確認と登録は1つのトランザクションで行い、途中で失敗した場合は何も登録しない
+	// records はバリデーション済みで、IDを指定していること
+	ImportService(ctx context.Context, records []*model.CatalogRecord, dryRun bool) (*ImportResult, error)
+	// 歌手、アルバムの順にID順ですべての件を emit に渡す (論理削除したものは含めない)
+	// 論理削除した歌手は書き出さないため、アルバムからその歌手の参加を外して渡す
+	// emit がエラーを返した場合はそこで止める
+	ExportService(ctx context.Context, emit func(*model.CatalogRecord) error) error
+}
+
+// 一括取り込みの結果
+type ImportResult struct {
+	Singers int  `json:"singers"` // 登録した (dryRun の場合は登録できる) 歌手の数
+	Albums  int  `json:"albums"`  // 登録した (dryRun の場合は登録できる) アルバムの数
+	DryRun  bool `json:"dry_run"`
+}
+
+// 一括取り込みの1件の問題
+type ImportError struct {
+	Index   int    // records のインデックス
+	Field   string // 項目名 (例: "singer.id", "album.artists[0].singer_id")
+	Code    string // duplicate: 取り込むデータの中で重複, conflict: 登録済みのデータと重複, not_found: 参照先がない
+	Message string
+}
+
+// 一括取り込みの前の確認で見つかった問題
+// apperr.ErrValidation をラップしているので 422 Unprocessable Entity になる
+type ImportErrors []ImportError
+
+func (e ImportErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, ie := range e {
+		msgs = append(msgs, fmt.Sprintf("record %d: %s: %s", ie.Index, ie.Field, ie.Message))
+	}
+	return "import check failed: " + strings.Join(msgs, "; ")
+}
+
+func (e ImportErrors) Unwrap() error {
+	return apperr.ErrValidation
+}
+
+// 書き出しで一度に読み込む件数
+const exportPageSize = 500
+
+type catalogService struct {
+	singerRepository repository.SingerRepository
+	albumRepository  repository.AlbumRepository
+}
+
+var _ CatalogService = (*catalogService)(nil)
+
+// コンストラクタ
+func NewCatalogService(singerRepository repository.SingerRepository, albumRepository repository.AlbumRepository) *catalogService {
+	return &catalogService{
+		singerRepository: singerRepository,
+		albumRepository:  albumRepository,
+	}
+}
+
+func (s *catalogService) ImportService(ctx context.Context, records []*model.CatalogRecord, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun}
+	err := withinTx(ctx, s.singerRepository, func(ctx context.Context) error {
+		if err := s.checkImport(ctx, records); err != nil {
+			return err
+		}
+		for _, rec := range records {
+			if rec.Singer != nil {
+				result.Singers++
+			} else {
+				result.Albums++
+			}
+		}
+		if dryRun {
+			return nil
+		}
+
+		// アルバムが参照する歌手から先に登録する
+		singers, albums := 0, 0
+		for _, rec := range records {
+			if rec.Singer == nil {
+				continue
+			}
+			if err := s.singerRepository.Add(ctx, rec.Singer); err != nil {
+				return fmt.Errorf("import stopped after %d singers: singer %d: %w", singers, rec.Singer.ID, err)
+			}
+			singers++
+		}
+		for _, rec := range records {
+			if rec.Album == nil {
+				continue
+			}
+			if err := s.albumRepository.Add(ctx, rec.Album); err != nil {
+				return fmt.Errorf("import stopped after %d singers and %d albums: album %d: %w", singers, albums, rec.Album.ID, err)
+			}
+			albums++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 取り込むデータの重複と、登録済みのデータとの重複・参照先の有無を確認する
+func (s *catalogService) checkImport(ctx context.Context, records []*model.CatalogRecord) error {
+	var errs ImportErrors
+	add := func(index int, field, code, message string) {
+		errs = append(errs, ImportError{Index: index, Field: field, Code: code, Message: message})
+	}
+
+	// 取り込むデータの中での重複
+	type nameKey struct{ name, country string }
+	singerIDs := map[model.SingerID]bool{}
+	singerNames := map[nameKey]bool{}
+	albumIDs := map[model.AlbumID]bool{}
+	for i, rec := range records {
+		switch {
+		case rec.Singer != nil:
+			if singerIDs[rec.Singer.ID] {
+				add(i, "singer.id", "duplicate", "is used by another singer in the import")
+			}
+			singerIDs[rec.Singer.ID] = true
+			key := nameKey{rec.Singer.Name, rec.Singer.Country}
+			if singerNames[key] {
+				add(i, "singer.name", "duplicate", "name and country are used by another singer in the import")
+			}
+			singerNames[key] = true
+		case rec.Album != nil:
+			if albumIDs[rec.Album.ID] {
+				add(i, "album.id", "duplicate", "is used by another album in the import")
+			}
+			albumIDs[rec.Album.ID] = true
+		}
+	}
+
+	// 登録済みのデータとの重複
+	// IDは論理削除したものとも重複できないため、論理削除したものも含めてまとめて取得する
+	existingSingers := map[model.SingerID]*model.Singer{}
+	if len(singerIDs) > 0 {
+		ids := make([]model.SingerID, 0, len(singerIDs))
+		for id := range singerIDs {
+			ids = append(ids, id)
+		}
+		found, err := s.singerRepository.Find(ctx, repository.SingerFilter{IDs: ids, IncludeDeleted: true}, repository.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, singer := range found {
+			existingSingers[singer.ID] = singer
+		}
+	}
+	// 名前と国の組は論理削除していない歌手の間でだけ一意にする
+	takenNames := map[nameKey]bool{}
+	if len(singerNames) > 0 {
+		names := make([]repository.SingerName, 0, len(singerNames))
+		for key := range singerNames {
+			names = append(names, repository.SingerName{Name: key.name, Country: key.country})
+		}
+		found, err := s.singerRepository.Find(ctx, repository.SingerFilter{Names: names}, repository.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, singer := range found {
+			takenNames[nameKey{singer.Name, singer.Country}] = true
+		}
+	}
+	existingAlbums := map[model.AlbumID]*model.Album{}
+	if len(albumIDs) > 0 {
+		ids := make([]model.AlbumID, 0, len(albumIDs))
+		for id := range albumIDs {
+			ids = append(ids, id)
+		}
+		found, err := s.albumRepository.Find(ctx, repository.AlbumFilter{IDs: ids, IncludeDeleted: true}, repository.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, album := range found {
+			existingAlbums[album.ID] = album
+		}
+	}
+	for i, rec := range records {
+		switch {
+		case rec.Singer != nil:
+			if existing, ok := existingSingers[rec.Singer.ID]; ok {
+				add(i, "singer.id", "conflict", existsMessage("singer", int(rec.Singer.ID), existing.DeletedAt != nil))
+			}
+			if takenNames[nameKey{rec.Singer.Name, rec.Singer.Country}] {
+				add(i, "singer.name", "conflict", "a singer with the same name and country already exists")
+			}
+		case rec.Album != nil:
+			if existing, ok := existingAlbums[rec.Album.ID]; ok {
+				add(i, "album.id", "conflict", existsMessage("album", int(rec.Album.ID), existing.DeletedAt != nil))
+			}
+		}
+	}
+
+	// アルバムが参照する歌手は、取り込むデータか登録済みのデータに存在すること
+	var refs []model.SingerID
+	for _, rec := range records {
+		if rec.Album == nil {
+			continue
+		}
+		for _, artist := range rec.Album.Artists {
+			if !singerIDs[artist.SingerID] {
+				refs = append(refs, artist.SingerID)
+			}
+		}
+	}
+	found, err := s.singerRepository.GetMany(ctx, refs)
+	if err != nil {
+		return err
+	}
+	foundIDs := make(map[model.SingerID]bool, len(found))
+	for _, singer := range found {
+		foundIDs[singer.ID] = true
+	}
+	for i, rec := range records {
+		if rec.Album == nil {
+			continue
+		}
+		for j, artist := range rec.Album.Artists {
+			if !singerIDs[artist.SingerID] && !foundIDs[artist.SingerID] {
+				add(i, fmt.Sprintf("album.artists[%d].singer_id", j), "not_found", fmt.Sprintf("singer %d does not exist", artist.SingerID))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// 登録済みのIDと重複したときのメッセージ
+func existsMessage(kind string, id int, deleted bool) string {
+	if deleted {
+		return fmt.Sprintf("%s %d already exists (deleted)", kind, id)
+	}
+	return fmt.Sprintf("%s %d already exists", kind, id)
+}
+
+func (s *catalogService) ExportService(ctx context.Context, emit func(*model.CatalogRecord) error) error {
+	// 書き出し中に追加・削除があっても重複や抜けがないよう、IDのカーソルで順に読み込む
+	opts := repository.ListOptions{Limit: exportPageSize}
+	for {
+		singers, err := s.singerRepository.GetAll(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, singer := range singers {
+			if err := emit(&model.CatalogRecord{Singer: singer}); err != nil {
+				return err
+			}
+		}
+		if len(singers) < exportPageSize {
+			break
+		}
+		opts.After = repository.SingerCursor(singers[len(singers)-1], repository.SortByID)
+	}
+
+	opts = repository.ListOptions{Limit: exportPageSize}
+	for {
+		albums, err := s.albumRepository.GetAll(ctx, opts)
+		if err != nil {
+			return err
+		}
+		live, err := s.liveArtists(ctx, albums)
+		if err != nil {
+			return err
+		}
+		for _, album := range albums {
+			if err := emit(&model.CatalogRecord{Album: withoutArtists(album, live)}); err != nil {
+				return err
+			}
+		}
+		if len(albums) < exportPageSize {
+			break
+		}
+		opts.After = repository.AlbumCursor(albums[len(albums)-1], repository.SortByID)
+	}
+	return nil
+}
+
+// アルバムに参加している歌手のうち、論理削除していない歌手のIDを返す
+func (s *catalogService) liveArtists(ctx context.Context, albums []*model.Album) (map[model.SingerID]bool, error) {
+	var ids []model.SingerID
+	for _, album := range albums {
+		for _, artist := range album.Artists {
+			ids = append(ids, artist.SingerID)
+		}
+	}
+	singers, err := s.singerRepository.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[model.SingerID]bool, len(singers))
+	for _, singer := range singers {
+		live[singer.ID] = true
+	}
+	return live, nil
+}
+
+// live に含まれない歌手の参加を外したアルバムを返す
+// 外す歌手がいない場合はそのまま返し、いる場合はコピーを書き換える
+func withoutArtists(album *model.Album, live map[model.SingerID]bool) *model.Album {
+	artists := make([]model.AlbumArtist, 0, len(album.Artists))
+	for _, artist := range album.Artists {
+		if live[artist.SingerID] {
+			artists = append(artists, artist)
+		}
+	}
+	if len(artists) == len(album.Artists) {
+		return album
+	}
+	copied := *album
+	copied.Artists = artists
+	copied.SingerID = 0
+	copied.SyncArtists()
+	return &copied
+}
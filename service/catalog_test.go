@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// 指定したアルバムの登録に失敗する AlbumRepository
+type failingAlbumRepository struct {
+	repository.AlbumRepository
+	failID model.AlbumID
+}
+
+func (r *failingAlbumRepository) Add(ctx context.Context, album *model.Album) error {
+	if album.ID == r.failID {
+		return errors.New("disk full")
+	}
+	return r.AlbumRepository.Add(ctx, album)
+}
+
+func (r *failingAlbumRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.AlbumRepository, fn)
+}
+
+// 登録の途中で失敗した場合は、それまでに登録した件も残さない
+func TestImportServiceRollback(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository){
+		"memorydb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository) {
+			return memorydb.NewSingerRepository(), memorydb.NewAlbumRepository()
+		},
+		"sqldb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository) {
+			db, err := sqldb.Open(ctx, ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return sqldb.NewSingerRepository(db), sqldb.NewAlbumRepository(db)
+		},
+	}
+	for name, newRepos := range backends {
+		t.Run(name, func(t *testing.T) {
+			singerRepo, albumRepo := newRepos(t)
+			assert.NoError(t, singerRepo.Add(ctx, &model.Singer{ID: 1, Name: "Alice"}))
+
+			svc := NewCatalogService(singerRepo, &failingAlbumRepository{AlbumRepository: albumRepo, failID: 2})
+			_, err := svc.ImportService(ctx, []*model.CatalogRecord{
+				{Singer: &model.Singer{ID: 2, Name: "Bella"}},
+				{Album: &model.Album{ID: 1, Title: "A1", SingerID: 1}},
+				{Album: &model.Album{ID: 2, Title: "B1", SingerID: 2}},
+			}, false)
+			assert.ErrorContains(t, err, "disk full")
+
+			singers, err := singerRepo.Find(ctx, repository.SingerFilter{IncludeDeleted: true}, repository.ListOptions{})
+			assert.NoError(t, err)
+			if assert.Len(t, singers, 1) {
+				assert.Equal(t, "Alice", singers[0].Name)
+			}
+			albums, err := albumRepo.Find(ctx, repository.AlbumFilter{IncludeDeleted: true}, repository.ListOptions{})
+			assert.NoError(t, err)
+			assert.Empty(t, albums)
+
+			// 取り消したあとも同じIDで登録できる
+			assert.NoError(t, albumRepo.Add(ctx, &model.Album{ID: 1, Title: "A1", SingerID: 1}))
+		})
+	}
+}
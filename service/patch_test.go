@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// 取得した直後にほかのリクエストが歌手を更新する SingerRepository
+type staleSingerRepository struct {
+	repository.SingerRepository
+}
+
+func (r *staleSingerRepository) Get(ctx context.Context, id model.SingerID) (*model.Singer, error) {
+	singer, err := r.SingerRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	updated := *singer
+	updated.Country = "JP"
+	updated.Version = 0
+	if err := r.SingerRepository.Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+	return singer, nil
+}
+
+// 取得した直後にほかのリクエストがアルバムを更新する AlbumRepository
+type staleAlbumRepository struct {
+	repository.AlbumRepository
+}
+
+func (r *staleAlbumRepository) Get(ctx context.Context, id model.AlbumID) (*model.Album, error) {
+	album, err := r.AlbumRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	updated := *album
+	updated.Label = "Other Label"
+	updated.Version = 0
+	if err := r.AlbumRepository.Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// 部分更新は取得したときのバージョンを条件に書き込み、その間の更新を上書きしない
+func TestPatchLostUpdate(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository){
+		"memorydb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository) {
+			return memorydb.NewSingerRepository(), memorydb.NewAlbumRepository()
+		},
+		"sqldb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository) {
+			db, err := sqldb.Open(ctx, ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return sqldb.NewSingerRepository(db), sqldb.NewAlbumRepository(db)
+		},
+	}
+	for name, newRepos := range backends {
+		t.Run(name, func(t *testing.T) {
+			singerRepo, albumRepo := newRepos(t)
+			assert.NoError(t, singerRepo.Add(ctx, &model.Singer{Name: "Alice"}))
+			assert.NoError(t, albumRepo.Add(ctx, &model.Album{Title: "A1", SingerID: 1}))
+
+			t.Run("Singer", func(t *testing.T) {
+				svc := NewSingerService(&staleSingerRepository{singerRepo}, albumRepo, SingerDeleteRestrict)
+				_, err := svc.PatchSingerService(ctx, 1, func(singer *model.Singer) error {
+					// マージパッチを構造体に戻すと json:"-" の Version は 0 になる
+					singer.Name = "Alicia"
+					singer.Version = 0
+					return nil
+				})
+				assert.True(t, errors.Is(err, apperr.ErrPreconditionFailed), err)
+
+				singer, err := singerRepo.Get(ctx, 1)
+				assert.NoError(t, err)
+				assert.Equal(t, "Alice", singer.Name)
+				assert.Equal(t, "JP", singer.Country)
+			})
+
+			t.Run("Album", func(t *testing.T) {
+				svc := NewAlbumService(&staleAlbumRepository{albumRepo}, singerRepo)
+				_, err := svc.PatchAlbumService(ctx, 1, func(album *model.Album) error {
+					// マージパッチを構造体に戻すと json:"-" の Version は 0 になる
+					album.Title = "A1 (Deluxe)"
+					album.Version = 0
+					return nil
+				})
+				assert.True(t, errors.Is(err, apperr.ErrPreconditionFailed), err)
+
+				album, err := albumRepo.Get(ctx, 1)
+				assert.NoError(t, err)
+				assert.Equal(t, "A1", album.Title)
+				assert.Equal(t, "Other Label", album.Label)
+			})
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// 論理削除した歌手・アルバムの完全削除
+// アルバムのトラックはアルバムと一緒に削除し、完全に削除した歌手は残っているアルバムの参加から外す
+type PurgeService interface {
+	// before より前に論理削除した歌手・アルバムを完全に削除し、削除した件数を返す
+	PurgeService(ctx context.Context, before time.Time) (singers, albums int, err error)
+}
+
+type purgeService struct {
+	singerRepository repository.SingerRepository
+	albumRepository  repository.AlbumRepository
+	trackRepository  repository.TrackRepository
+}
+
+var _ PurgeService = (*purgeService)(nil)
+
+// コンストラクタ
+func NewPurgeService(singerRepository repository.SingerRepository, albumRepository repository.AlbumRepository, trackRepository repository.TrackRepository) *purgeService {
+	return &purgeService{
+		singerRepository: singerRepository,
+		albumRepository:  albumRepository,
+		trackRepository:  trackRepository,
+	}
+}
+
+// 1つのトランザクションで行い、途中で失敗した場合は何も削除しない
+func (s *purgeService) PurgeService(ctx context.Context, before time.Time) (int, int, error) {
+	var singers, albums int
+	err := withinTx(ctx, s.singerRepository, func(ctx context.Context) error {
+		// 歌手を参照しているアルバムから先に削除する
+		albumIDs, err := s.albumRepository.Purge(ctx, before)
+		if err != nil {
+			return err
+		}
+		if err := s.trackRepository.DeleteByAlbums(ctx, albumIDs); err != nil {
+			return err
+		}
+		singerIDs, err := s.singerRepository.Purge(ctx, before)
+		if err != nil {
+			return err
+		}
+		// 歌手より先に論理削除し、あとから戻したアルバムには削除した歌手の参加が残っていることがある
+		for _, id := range singerIDs {
+			if err := s.albumRepository.UnsetSinger(ctx, id); err != nil {
+				return err
+			}
+		}
+		singers, albums = len(singerIDs), len(albumIDs)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return singers, albums, nil
+}
+
+// 保存期間 (retention) を過ぎた論理削除データを interval ごとに完全削除する
+// ctx が終了するまで戻らない
+func RunPurgeJob(ctx context.Context, s PurgeService, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			singers, albums, err := s.PurgeService(ctx, now.Add(-retention))
+			if err != nil {
+				slog.Error("purge failed", "error", err)
+				continue
+			}
+			if singers > 0 || albums > 0 {
+				slog.Info("purged deleted records", "singers", singers, "albums", albums)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// 保存期間を過ぎた論理削除データだけを完全に削除する
+// 完全に削除したアルバムのトラックも削除する
+func TestPurgeService(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository, repository.TrackRepository){
+		"memorydb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository, repository.TrackRepository) {
+			return memorydb.NewSingerRepository(), memorydb.NewAlbumRepository(), memorydb.NewTrackRepository()
+		},
+		"sqldb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository, repository.TrackRepository) {
+			db, err := sqldb.Open(ctx, ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return sqldb.NewSingerRepository(db), sqldb.NewAlbumRepository(db), sqldb.NewTrackRepository(db)
+		},
+	}
+	for name, newRepos := range backends {
+		t.Run(name, func(t *testing.T) {
+			singerRepo, albumRepo, trackRepo := newRepos(t)
+			for _, s := range []*model.Singer{{Name: "Alice"}, {Name: "Bella"}} {
+				assert.NoError(t, singerRepo.Add(ctx, s))
+			}
+			for _, a := range []*model.Album{{Title: "A1", SingerID: 1}, {Title: "B1", SingerID: 2}} {
+				assert.NoError(t, albumRepo.Add(ctx, a))
+				assert.NoError(t, trackRepo.Add(ctx, &model.Track{AlbumID: a.ID, Title: a.Title + "-1", DurationSeconds: 180}))
+			}
+
+			// 歌手1とそのアルバムを論理削除する
+			singerSvc := NewSingerService(singerRepo, albumRepo, SingerDeleteCascade)
+			assert.NoError(t, singerSvc.DeleteSingerService(ctx, 1, 0))
+
+			svc := NewPurgeService(singerRepo, albumRepo, trackRepo)
+
+			// 保存期間内のデータは残す
+			singers, albums, err := svc.PurgeService(ctx, time.Now().Add(-time.Hour))
+			assert.NoError(t, err)
+			assert.Equal(t, 0, singers)
+			assert.Equal(t, 0, albums)
+			_, err = singerRepo.Restore(ctx, 1)
+			assert.NoError(t, err)
+			assert.NoError(t, singerSvc.DeleteSingerService(ctx, 1, 0))
+
+			singers, albums, err = svc.PurgeService(ctx, time.Now().Add(time.Second))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, singers)
+			assert.Equal(t, 1, albums)
+
+			// 完全に削除したデータは戻せない
+			_, err = singerRepo.Restore(ctx, 1)
+			assert.True(t, errors.Is(err, apperr.ErrNotFound))
+			_, err = albumRepo.Restore(ctx, 1)
+			assert.True(t, errors.Is(err, apperr.ErrNotFound))
+
+			// 削除していないデータはそのまま
+			all, err := albumRepo.Find(ctx, repository.AlbumFilter{IncludeDeleted: true}, repository.ListOptions{})
+			assert.NoError(t, err)
+			if assert.Len(t, all, 1) {
+				assert.Equal(t, "B1", all[0].Title)
+			}
+			tracks, err := trackRepo.GetByAlbums(ctx, []model.AlbumID{1, 2})
+			assert.NoError(t, err)
+			if assert.Len(t, tracks, 1) {
+				assert.Equal(t, model.AlbumID(2), tracks[0].AlbumID)
+			}
+		})
+	}
+}
+
+// 完全に削除した歌手は、残っているアルバムの参加から外す
+func TestPurgeServiceUnsetSinger(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository, repository.TrackRepository){
+		"memorydb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository, repository.TrackRepository) {
+			return memorydb.NewSingerRepository(), memorydb.NewAlbumRepository(), memorydb.NewTrackRepository()
+		},
+		"sqldb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository, repository.TrackRepository) {
+			db, err := sqldb.Open(ctx, ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return sqldb.NewSingerRepository(db), sqldb.NewAlbumRepository(db), sqldb.NewTrackRepository(db)
+		},
+	}
+	for name, newRepos := range backends {
+		t.Run(name, func(t *testing.T) {
+			singerRepo, albumRepo, trackRepo := newRepos(t)
+			for _, s := range []*model.Singer{{Name: "Alice"}, {Name: "Bella"}} {
+				assert.NoError(t, singerRepo.Add(ctx, s))
+			}
+			assert.NoError(t, albumRepo.Add(ctx, &model.Album{Title: "AB1", Artists: []model.AlbumArtist{
+				{SingerID: 1, Role: model.ArtistRolePrimary},
+				{SingerID: 2, Role: model.ArtistRolePrimary},
+			}}))
+
+			// アルバムを論理削除してから歌手1を削除し、アルバムだけを戻す
+			albumSvc := NewAlbumService(albumRepo, singerRepo)
+			assert.NoError(t, albumSvc.DeleteAlbumService(ctx, 1, 0))
+			singerSvc := NewSingerService(singerRepo, albumRepo, SingerDeleteRestrict)
+			assert.NoError(t, singerSvc.DeleteSingerService(ctx, 1, 0))
+			_, err := albumSvc.RestoreAlbumService(ctx, 1)
+			assert.NoError(t, err)
+
+			svc := NewPurgeService(singerRepo, albumRepo, trackRepo)
+			singers, albums, err := svc.PurgeService(ctx, time.Now().Add(time.Second))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, singers)
+			assert.Equal(t, 0, albums)
+
+			album, err := albumRepo.Get(ctx, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, model.SingerID(2), album.SingerID)
+			assert.Equal(t, []model.AlbumArtist{{SingerID: 2, Role: model.ArtistRolePrimary}}, album.Artists)
+			byAlice, err := albumRepo.GetBySinger(ctx, 1)
+			assert.NoError(t, err)
+			assert.Empty(t, byAlice)
+		})
+	}
+}
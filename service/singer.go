@@ -2,36 +2,84 @@ package service
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
 	"github.com/pulse227/server-recruit-challenge-sample/model"
 	"github.com/pulse227/server-recruit-challenge-sample/repository"
 )
 
 type SingerService interface {
-	GetSingerListService(ctx context.Context) ([]*model.Singer, error)
+	GetSingerListService(ctx context.Context, filter repository.SingerFilter, opts repository.ListOptions) ([]*model.Singer, *repository.Cursor, error)
 	GetSingerService(ctx context.Context, singerID model.SingerID) (*model.Singer, error)
+	GetSingerMapService(ctx context.Context, singerIDs []model.SingerID) (map[model.SingerID]*model.Singer, error)
 	PostSingerService(ctx context.Context, singer *model.Singer) error
-	DeleteSingerService(ctx context.Context, singerID model.SingerID) error
+	PutSingerService(ctx context.Context, singer *model.Singer) error
+	PatchSingerService(ctx context.Context, singerID model.SingerID, apply func(*model.Singer) error) (*model.Singer, error)
+	// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+	DeleteSingerService(ctx context.Context, singerID model.SingerID, version int) error
+	// 論理削除した歌手を元に戻す
+	RestoreSingerService(ctx context.Context, singerID model.SingerID) (*model.Singer, error)
+}
+
+// アルバムから参照されている歌手を削除するときの方針
+type SingerDeletePolicy string
+
+const (
+	// アルバムから参照されている場合は削除しない (409 Conflict)
+	SingerDeleteRestrict SingerDeletePolicy = "restrict"
+	// 歌手のアルバムもあわせて削除する
+	SingerDeleteCascade SingerDeletePolicy = "cascade"
+	// アルバムは残し、歌手の紐づけだけを外す
+	SingerDeleteOrphan SingerDeletePolicy = "orphan"
+)
+
+// 文字列から削除方針を取得する
+func ParseSingerDeletePolicy(s string) (SingerDeletePolicy, error) {
+	switch p := SingerDeletePolicy(s); p {
+	case SingerDeleteRestrict, SingerDeleteCascade, SingerDeleteOrphan:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown singer delete policy %q (restrict, cascade, orphan)", s)
 }
 
 type singerService struct {
 	singerRepository repository.SingerRepository
+	albumRepository  repository.AlbumRepository
+	deletePolicy     SingerDeletePolicy
 }
 
 // singerServiceがSingerServiceを実装
 var _ SingerService = (*singerService)(nil)
 
 // コンストラクタ
-func NewSingerService(singerRepository repository.SingerRepository) *singerService {
-	return &singerService{singerRepository: singerRepository}
+func NewSingerService(singerRepository repository.SingerRepository, albumRepository repository.AlbumRepository, deletePolicy SingerDeletePolicy) *singerService {
+	return &singerService{
+		singerRepository: singerRepository,
+		albumRepository:  albumRepository,
+		deletePolicy:     deletePolicy,
+	}
 }
 
-func (s *singerService) GetSingerListService(ctx context.Context) ([]*model.Singer, error) {
-	singers, err := s.singerRepository.GetAll(ctx)
+// 検索条件に一致する歌手の一覧を取得する
+// 続きのページがある場合は次のページのカーソルも返す
+func (s *singerService) GetSingerListService(ctx context.Context, filter repository.SingerFilter, opts repository.ListOptions) ([]*model.Singer, *repository.Cursor, error) {
+	// 続きがあるかを判定するため1件多く取得する
+	fetch := opts
+	if opts.Limit > 0 {
+		fetch.Limit = opts.Limit + 1
+	}
+	singers, err := s.singerRepository.Find(ctx, filter, fetch)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return singers, nil
+
+	var next *repository.Cursor
+	if opts.Limit > 0 && len(singers) > opts.Limit {
+		singers = singers[:opts.Limit]
+		next = repository.SingerCursor(singers[len(singers)-1], opts.SortKey())
+	}
+	return singers, next, nil
 }
 
 func (s *singerService) GetSingerService(ctx context.Context, singerID model.SingerID) (*model.Singer, error) {
@@ -42,6 +90,20 @@ func (s *singerService) GetSingerService(ctx context.Context, singerID model.Sin
 	return singer, nil
 }
 
+// 複数の歌手をまとめて取得し、IDをキーにしたマップで返す
+// 存在しないIDはマップに含まれない
+func (s *singerService) GetSingerMapService(ctx context.Context, singerIDs []model.SingerID) (map[model.SingerID]*model.Singer, error) {
+	singers, err := s.singerRepository.GetMany(ctx, singerIDs)
+	if err != nil {
+		return nil, err
+	}
+	singerMap := make(map[model.SingerID]*model.Singer, len(singers))
+	for _, singer := range singers {
+		singerMap[singer.ID] = singer
+	}
+	return singerMap, nil
+}
+
 func (s *singerService) PostSingerService(ctx context.Context, singer *model.Singer) error {
 	if err := s.singerRepository.Add(ctx, singer); err != nil {
 		return err
@@ -49,9 +111,76 @@ func (s *singerService) PostSingerService(ctx context.Context, singer *model.Sin
 	return nil
 }
 
-func (s *singerService) DeleteSingerService(ctx context.Context, singerID model.SingerID) error {
-	if err := s.singerRepository.Delete(ctx, singerID); err != nil {
+// 歌手を置き換える
+func (s *singerService) PutSingerService(ctx context.Context, singer *model.Singer) error {
+	if err := s.singerRepository.Update(ctx, singer); err != nil {
 		return err
 	}
 	return nil
 }
+
+// 歌手を部分更新する
+// apply には現在の歌手のコピーが渡されるので、変更したい項目だけを書き換える
+// 取得してから更新するまでにほかの更新があった場合は apperr.ErrPreconditionFailed を返す
+func (s *singerService) PatchSingerService(ctx context.Context, singerID model.SingerID, apply func(*model.Singer) error) (*model.Singer, error) {
+	current, err := s.singerRepository.Get(ctx, singerID)
+	if err != nil {
+		return nil, err
+	}
+
+	singer := *current
+	if err := apply(&singer); err != nil {
+		return nil, err
+	}
+	// IDは変更させない
+	singer.ID = singerID
+	// 取得したときのバージョンのままの場合だけ更新し、その間のほかの更新を上書きしない
+	singer.Version = current.Version
+
+	if err := s.singerRepository.Update(ctx, &singer); err != nil {
+		return nil, err
+	}
+	return &singer, nil
+}
+
+// 歌手を論理削除し、参照しているアルバムを削除方針に従って扱う
+// 1つのトランザクションで行い、途中で失敗した場合は何も変更しない
+func (s *singerService) DeleteSingerService(ctx context.Context, singerID model.SingerID, version int) error {
+	return withinTx(ctx, s.singerRepository, func(ctx context.Context) error {
+		if s.deletePolicy != SingerDeleteCascade && s.deletePolicy != SingerDeleteOrphan {
+			// 存在とバージョンを確認してから参照を確認する
+			current, err := s.singerRepository.Get(ctx, singerID)
+			if err != nil {
+				return err
+			}
+			if version != 0 && current.Version != version {
+				return fmt.Errorf("singer %d: %w", singerID, apperr.ErrPreconditionFailed)
+			}
+			albums, err := s.albumRepository.GetBySinger(ctx, singerID)
+			if err != nil {
+				return err
+			}
+			if len(albums) > 0 {
+				return fmt.Errorf("singer %d is referenced by %d albums: %w", singerID, len(albums), apperr.ErrConflict)
+			}
+		}
+
+		// バージョンを確認して歌手を削除してから、アルバムを変更する
+		if err := s.singerRepository.Delete(ctx, singerID, version); err != nil {
+			return err
+		}
+		switch s.deletePolicy {
+		case SingerDeleteCascade:
+			return s.albumRepository.DeleteBySinger(ctx, singerID)
+		case SingerDeleteOrphan:
+			return s.albumRepository.UnsetSinger(ctx, singerID)
+		}
+		return nil
+	})
+}
+
+// 論理削除した歌手を元に戻す
+// 一緒に削除されたアルバム (cascade) は戻さないので、必要に応じて個別に戻す
+func (s *singerService) RestoreSingerService(ctx context.Context, singerID model.SingerID) (*model.Singer, error) {
+	return s.singerRepository.Restore(ctx, singerID)
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pulse227/server-recruit-challenge-sample/apperr"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/memorydb"
+	"github.com/pulse227/server-recruit-challenge-sample/infra/sqldb"
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// 削除の直前にほかのリクエストが歌手を更新する SingerRepository
+type racingSingerRepository struct {
+	repository.SingerRepository
+}
+
+func (r *racingSingerRepository) Delete(ctx context.Context, id model.SingerID, version int) error {
+	singer, err := r.SingerRepository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	updated := *singer
+	updated.Name += "!"
+	if err := r.SingerRepository.Update(ctx, &updated); err != nil {
+		return err
+	}
+	return r.SingerRepository.Delete(ctx, id, version)
+}
+
+func (r *racingSingerRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.SingerRepository, fn)
+}
+
+// 参照の確認の直後にほかのリクエストがアルバムを登録する AlbumRepository
+type racingAlbumRepository struct {
+	repository.AlbumRepository
+	post func() // 別の goroutine でアルバムを登録する
+}
+
+func (r *racingAlbumRepository) GetBySinger(ctx context.Context, singerID model.SingerID) ([]*model.Album, error) {
+	albums, err := r.AlbumRepository.GetBySinger(ctx, singerID)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.post()
+	}()
+	// トランザクションの中では登録が削除の完了を待つので、待ちきらずに進める
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+	}
+	return albums, err
+}
+
+func (r *racingAlbumRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return withinTx(ctx, r.AlbumRepository, fn)
+}
+
+// 歌手の削除は、バージョンの確認とアルバムの変更をまとめて行う
+func TestDeleteSingerService(t *testing.T) {
+	ctx := context.Background()
+	backends := map[string]func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository){
+		"memorydb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository) {
+			return memorydb.NewSingerRepository(), memorydb.NewAlbumRepository()
+		},
+		"sqldb": func(t *testing.T) (repository.SingerRepository, repository.AlbumRepository) {
+			db, err := sqldb.Open(ctx, ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return sqldb.NewSingerRepository(db), sqldb.NewAlbumRepository(db)
+		},
+	}
+	for name, newRepos := range backends {
+		t.Run(name, func(t *testing.T) {
+			// 歌手の削除がバージョンの不一致で失敗した場合、アルバムは削除しない
+			t.Run("CascadePreconditionFailed", func(t *testing.T) {
+				singerRepo, albumRepo := newRepos(t)
+				assert.NoError(t, singerRepo.Add(ctx, &model.Singer{Name: "Alice"}))
+				assert.NoError(t, albumRepo.Add(ctx, &model.Album{Title: "A1", SingerID: 1}))
+
+				svc := NewSingerService(&racingSingerRepository{singerRepo}, albumRepo, SingerDeleteCascade)
+				err := svc.DeleteSingerService(ctx, 1, 1)
+				assert.True(t, errors.Is(err, apperr.ErrPreconditionFailed), err)
+
+				albums, err := albumRepo.GetBySinger(ctx, 1)
+				assert.NoError(t, err)
+				assert.Len(t, albums, 1)
+			})
+
+			// 参照の確認と削除の間に登録されたアルバムが、削除した歌手を参照しない
+			t.Run("RestrictConcurrentPost", func(t *testing.T) {
+				singerRepo, albumRepo := newRepos(t)
+				assert.NoError(t, singerRepo.Add(ctx, &model.Singer{Name: "Alice"}))
+
+				albumSvc := NewAlbumService(albumRepo, singerRepo)
+				postErr := make(chan error, 1)
+				racing := &racingAlbumRepository{AlbumRepository: albumRepo, post: func() {
+					postErr <- albumSvc.PostAlbumService(ctx, &model.Album{Title: "A1", SingerID: 1})
+				}}
+				svc := NewSingerService(singerRepo, racing, SingerDeleteRestrict)
+				assert.NoError(t, svc.DeleteSingerService(ctx, 1, 0))
+
+				// 登録は削除のトランザクションが終わるまで待ち、歌手がいないため失敗する
+				select {
+				case err := <-postErr:
+					assert.True(t, errors.Is(err, apperr.ErrValidation), err)
+				case <-time.After(time.Second):
+					t.Fatal("album post did not finish")
+				}
+				all, err := albumRepo.Find(ctx, repository.AlbumFilter{IncludeDeleted: true}, repository.ListOptions{})
+				assert.NoError(t, err)
+				assert.Empty(t, all)
+			})
+
+			// 同時に登録と削除をしても、登録したアルバムが削除した歌手を参照しない
+			t.Run("RestrictConcurrentStress", func(t *testing.T) {
+				singerRepo, albumRepo := newRepos(t)
+				singerSvc := NewSingerService(singerRepo, albumRepo, SingerDeleteRestrict)
+				albumSvc := NewAlbumService(albumRepo, singerRepo)
+				for i := 0; i < 50; i++ {
+					singer := &model.Singer{Name: fmt.Sprintf("Singer %d", i)}
+					assert.NoError(t, singerRepo.Add(ctx, singer))
+
+					var wg sync.WaitGroup
+					wg.Add(2)
+					go func() {
+						defer wg.Done()
+						albumSvc.PostAlbumService(ctx, &model.Album{Title: singer.Name, SingerID: singer.ID})
+					}()
+					go func() {
+						defer wg.Done()
+						singerSvc.DeleteSingerService(ctx, singer.ID, 0)
+					}()
+					wg.Wait()
+				}
+
+				albums, err := albumRepo.GetAll(ctx, repository.ListOptions{})
+				assert.NoError(t, err)
+				for _, album := range albums {
+					_, err := singerRepo.Get(ctx, album.SingerID)
+					assert.NoError(t, err, "album %d references deleted singer %d", album.ID, album.SingerID)
+				}
+			})
+		})
+	}
+}
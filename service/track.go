@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pulse227/server-recruit-challenge-sample/model"
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// アルバムのトラック (/albums/{id}/tracks)
+// アルバムが存在しない (論理削除した場合も含む) 場合は NotFound を返す
+type TrackService interface {
+	GetTrackListService(ctx context.Context, albumID model.AlbumID) ([]*model.Track, error)
+	GetTrackService(ctx context.Context, albumID model.AlbumID, number int) (*model.Track, error)
+	PostTrackService(ctx context.Context, track *model.Track) error
+	PutTrackService(ctx context.Context, track *model.Track) error
+	// version が 0 でない場合は現在のバージョンと一致するときだけ削除する
+	DeleteTrackService(ctx context.Context, albumID model.AlbumID, number int, version int) error
+}
+
+type trackService struct {
+	trackRepository repository.TrackRepository
+	albumRepository repository.AlbumRepository
+}
+
+var _ TrackService = (*trackService)(nil)
+
+// コンストラクタ
+func NewTrackService(trackRepository repository.TrackRepository, albumRepository repository.AlbumRepository) *trackService {
+	return &trackService{
+		trackRepository: trackRepository,
+		albumRepository: albumRepository,
+	}
+}
+
+func (s *trackService) GetTrackListService(ctx context.Context, albumID model.AlbumID) ([]*model.Track, error) {
+	// アルバムの存在チェック
+	if _, err := s.albumRepository.Get(ctx, albumID); err != nil {
+		return nil, err
+	}
+	return s.trackRepository.GetByAlbum(ctx, albumID)
+}
+
+func (s *trackService) GetTrackService(ctx context.Context, albumID model.AlbumID, number int) (*model.Track, error) {
+	// アルバムの存在チェック
+	if _, err := s.albumRepository.Get(ctx, albumID); err != nil {
+		return nil, err
+	}
+	return s.trackRepository.Get(ctx, albumID, number)
+}
+
+func (s *trackService) PostTrackService(ctx context.Context, track *model.Track) error {
+	// アルバムの存在チェック
+	if _, err := s.albumRepository.Get(ctx, track.AlbumID); err != nil {
+		return err
+	}
+	return s.trackRepository.Add(ctx, track)
+}
+
+func (s *trackService) PutTrackService(ctx context.Context, track *model.Track) error {
+	// アルバムの存在チェック
+	if _, err := s.albumRepository.Get(ctx, track.AlbumID); err != nil {
+		return err
+	}
+	return s.trackRepository.Update(ctx, track)
+}
+
+func (s *trackService) DeleteTrackService(ctx context.Context, albumID model.AlbumID, number int, version int) error {
+	// アルバムの存在チェック
+	if _, err := s.albumRepository.Get(ctx, albumID); err != nil {
+		return err
+	}
+	return s.trackRepository.Delete(ctx, albumID, number, version)
+}
+
+// 複数のアルバムのトラックをまとめて取得し、アルバムIDをキーにしたマップで返す
+// トラックのないアルバムはマップに含まれない
+func (s *trackService) GetTrackMapService(ctx context.Context, albumIDs []model.AlbumID) (map[model.AlbumID][]*model.Track, error) {
+	tracks, err := s.trackRepository.GetByAlbums(ctx, albumIDs)
+	if err != nil {
+		return nil, err
+	}
+	trackMap := make(map[model.AlbumID][]*model.Track, len(albumIDs))
+	for _, track := range tracks {
+		trackMap[track.AlbumID] = append(trackMap[track.AlbumID], track)
+	}
+	return trackMap, nil
+}
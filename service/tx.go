@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pulse227/server-recruit-challenge-sample/repository"
+)
+
+// repo が repository.Transactor を実装していれば fn を1つのトランザクションで実行し、
+// 実装していなければそのまま実行する
+// fn の中では引数の ctx を使ってリポジトリを呼び出すこと
+func withinTx(ctx context.Context, repo interface{}, fn func(ctx context.Context) error) error {
+	if t, ok := repo.(repository.Transactor); ok {
+		return t.WithinTx(ctx, fn)
+	}
+	return fn(ctx)
+}